@@ -0,0 +1,35 @@
+package main
+
+import (
+	"log"
+
+	mailer "github.com/thedevsaddam/gomailer"
+)
+
+func main() {
+	// new mailer, every email is written as an .eml file instead of being sent
+	c := mailer.Configs{
+		Directory: "mails",
+	}
+	m, err := mailer.New(mailer.FILE, c)
+	checkError(err)
+
+	m.From("John Doe", "john@mail.com")
+	m.To("Jane Doe", "jane@mail.com")
+	m.Cc("Tom", "tom@mail.com")
+	m.Bcc("Batman", "batman@mail.com")
+	m.ReplyTo("Iron man", "iman@mail.com")
+
+	m.Subject("This is an urgent email")
+	m.BodyHTML("<html>Inline image here: <img src='cid:a.png'></html>")
+	m.AttachmentInlineFile("a.png")
+
+	err = m.Send()
+	checkError(err)
+}
+
+func checkError(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
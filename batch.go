@@ -0,0 +1,280 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type (
+	// Recipient describes a receipent of a batch with the data of their
+	// email. Without a hosted template every {{key}} of the subject and
+	// bodies is replaced by the value of key as is. With a hosted template
+	// the data is merged over the template data of the message, mailgun
+	// passes the merged values of the receipent keys as text
+	Recipient struct {
+		Address Address                `json:"address"`
+		Data    map[string]interface{} `json:"data,omitempty"`
+	}
+
+	// Batch describes one message sent to many receipents, each receipent
+	// gets an email of their own so nobody sees the others. The To, Cc and
+	// Bcc of the message must be empty
+	Batch struct {
+		Message    Message     `json:"message"`
+		Recipients []Recipient `json:"recipients"`
+	}
+
+	// BatchResult describes the outcome of a batch per receipent
+	BatchResult struct {
+		Requests   int               // Requests represents the number of api calls made
+		Recipients []RecipientResult // Recipients represents the outcome of every receipent in order
+	}
+
+	// RecipientResult describes the outcome of a receipent of a batch
+	RecipientResult struct {
+		Email     string
+		MessageID string // MessageID represents the provider message id, shared by the receipents of a request for some providers
+		Err       error  // Err represents the reason the email was not accepted, nil on success
+	}
+
+	// batchSender describes a driver whose provider accepts many personalized
+	// emails in a single api call
+	batchSender interface {
+		// batchSize return the max receipents per api call
+		batchSize() int
+		// verifyParams verify the required params of a single email
+		verifyParams(msg *Message) error
+		// sendBatch send the receipents, at most batchSize, in a single api call
+		sendBatch(ctx context.Context, m *Message, recipients []Recipient) ([]RecipientResult, error)
+	}
+
+	// batchPayloadLimiter describes a batch driver whose api repeats the whole
+	// email, attachments included, for every receipent of a call
+	batchPayloadLimiter interface {
+		// maxBatchPayload return the max size in bytes of the payload of a call
+		maxBatchPayload() int64
+	}
+)
+
+// SendBatch send a batch in the fewest api calls the driver allows, drivers
+// without batch support send one email per receipent. A receipent failure
+// does not stop the batch, the error reports how many failed
+func SendBatch(ctx context.Context, s Sender, b *Batch) (*BatchResult, error) {
+	if b.Message.receipents() > 0 {
+		return nil, errors.New("gomailer: the receipents of a batch go in Recipients, not To, Cc or Bcc")
+	}
+	if len(b.Recipients) <= 0 {
+		return nil, ErrNoRecipients
+	}
+	result := &BatchResult{}
+	bs, ok := s.(batchSender)
+	if !ok {
+		for _, r := range b.Recipients {
+			if r.Address.Email == "" {
+				result.add(r, "", ErrNoRecipients)
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				result.add(r, "", err)
+				continue
+			}
+			res, err := s.Send(ctx, b.Message.personalize(r))
+			result.Requests++
+			result.add(r, res.MessageID(), err)
+		}
+		return result, result.err()
+	}
+
+	// invalid receipents are reported and left out of the requests
+	valid := []Recipient{}
+	sizes := []int64{}
+	failed := map[int]error{}
+	for i, r := range b.Recipients {
+		if r.Address.Email == "" {
+			failed[i] = ErrNoRecipients
+			continue
+		}
+		m := b.Message.personalize(r)
+		if err := bs.verifyParams(m); err != nil {
+			failed[i] = err
+			continue
+		}
+		valid = append(valid, r)
+		sizes = append(sizes, m.payloadSize())
+	}
+	var maxPayload int64
+	if pl, ok := bs.(batchPayloadLimiter); ok {
+		maxPayload = pl.maxBatchPayload()
+	}
+	sent := map[string][]RecipientResult{}
+	for _, chunk := range batchChunks(valid, sizes, bs.batchSize(), maxPayload) {
+		var results []RecipientResult
+		err := ctx.Err()
+		if err == nil {
+			results, err = bs.sendBatch(ctx, &b.Message, chunk)
+			result.Requests++
+		}
+		if err != nil {
+			results = nil
+			for _, r := range chunk {
+				results = append(results, RecipientResult{Email: r.Address.Email, Err: err})
+			}
+		}
+		for _, r := range results {
+			sent[r.Email] = append(sent[r.Email], r)
+		}
+	}
+
+	// keep the order of the batch, a receipent listed twice takes the results in turn
+	for i, r := range b.Recipients {
+		if err, ok := failed[i]; ok {
+			result.add(r, "", err)
+			continue
+		}
+		rs := sent[r.Address.Email]
+		if len(rs) <= 0 {
+			result.add(r, "", fmt.Errorf("gomailer: no result for %s", r.Address.Email))
+			continue
+		}
+		result.Recipients = append(result.Recipients, rs[0])
+		sent[r.Address.Email] = rs[1:]
+	}
+	return result, result.err()
+}
+
+// batchChunks split the receipents into calls of at most size receipents
+// and, when maxPayload > 0, at most maxPayload bytes. A receipent whose email
+// alone is larger goes in a call of its own and is left to the provider
+func batchChunks(recipients []Recipient, sizes []int64, size int, maxPayload int64) [][]Recipient {
+	chunks := [][]Recipient{}
+	start := 0
+	var payload int64
+	for i := range recipients {
+		full := i-start >= size || (maxPayload > 0 && payload+sizes[i] > maxPayload)
+		if i > start && full {
+			chunks = append(chunks, recipients[start:i])
+			start, payload = i, 0
+		}
+		payload += sizes[i]
+	}
+	if start < len(recipients) {
+		chunks = append(chunks, recipients[start:])
+	}
+	return chunks
+}
+
+// add record the outcome of a receipent
+func (b *BatchResult) add(r Recipient, id string, err error) {
+	b.Recipients = append(b.Recipients, RecipientResult{Email: r.Address.Email, MessageID: id, Err: err})
+}
+
+// Failed return the receipents whose email was not accepted
+func (b *BatchResult) Failed() []RecipientResult {
+	failed := []RecipientResult{}
+	for _, r := range b.Recipients {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// err return an error wrapping the first failure if any receipent failed
+func (b *BatchResult) err() error {
+	failed := b.Failed()
+	if len(failed) <= 0 {
+		return nil
+	}
+	return fmt.Errorf("gomailer: %d of %d receipents failed: %w", len(failed), len(b.Recipients), failed[0].Err)
+}
+
+// personalize return the email of a receipent, the data is substituted or
+// merged over the template data
+func (m *Message) personalize(r Recipient) *Message {
+	c := m.clone()
+	c.To = []Address{r.Address}
+	if c.Template != "" {
+		c.TemplateData = r.merge(m.TemplateData)
+		return c
+	}
+	c.Subject = r.substitute(c.Subject)
+	c.HTML = r.substitute(c.HTML)
+	c.Text = r.substitute(c.Text)
+	return c
+}
+
+// merge return the receipent data over data
+func (r Recipient) merge(data map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for k, v := range data {
+		merged[k] = v
+	}
+	for k, v := range r.Data {
+		merged[k] = v
+	}
+	return merged
+}
+
+// substitute replace every {{key}} of s by the receipent value
+func (r Recipient) substitute(s string) string {
+	if s == "" || len(r.Data) <= 0 {
+		return s
+	}
+	pairs := []string{}
+	for k, v := range r.Data {
+		pairs = append(pairs, placeholder(k), fmt.Sprint(v))
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// placeholder return the placeholder of a key in the subject and bodies
+func placeholder(key string) string {
+	return "{{" + key + "}}"
+}
+
+// batchKeys return the sorted keys of the data of every receipent
+func batchKeys(recipients []Recipient) []string {
+	seen := map[string]bool{}
+	keys := []string{}
+	for _, r := range recipients {
+		for k := range r.Data {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// dataKeys return the sorted keys of template data
+func dataKeys(data map[string]interface{}) []string {
+	keys := []string{}
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// batchResults return the results of receipents sharing the message id of a request
+func batchResults(recipients []Recipient, id string) []RecipientResult {
+	results := []RecipientResult{}
+	for _, r := range recipients {
+		results = append(results, RecipientResult{Email: r.Address.Email, MessageID: id})
+	}
+	return results
+}
+
+// batchAddresses return the addresses of the receipents
+func batchAddresses(recipients []Recipient) []Address {
+	list := []Address{}
+	for _, r := range recipients {
+		list = append(list, r.Address)
+	}
+	return list
+}
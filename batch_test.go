@@ -0,0 +1,108 @@
+package gomailer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/thedevsaddam/gomailer/gomailertest"
+)
+
+func TestBatchChunks(t *testing.T) {
+	recipients := make([]Recipient, 7)
+	tests := []struct {
+		name       string
+		sizes      []int64
+		size       int
+		maxPayload int64
+		want       []int
+	}{
+		{"by count", []int64{1, 1, 1, 1, 1, 1, 1}, 3, 0, []int{3, 3, 1}},
+		{"by payload", []int64{4, 4, 4, 4, 4, 4, 4}, 5, 10, []int{2, 2, 2, 1}},
+		{"oversized alone", []int64{1, 20, 1, 1, 1, 1, 1}, 5, 10, []int{1, 1, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []int{}
+			for _, c := range batchChunks(recipients, tt.sizes, tt.size, tt.maxPayload) {
+				got = append(got, len(c))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got chunks %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchPayloadLimit(t *testing.T) {
+	srv := gomailertest.NewPostmark("token")
+	defer srv.Close()
+	s, _ := NewSender(POSTMARK, Configs{ServerToken: "token", BaseURL: srv.BaseURL()})
+
+	recipients := []Recipient{}
+	for i := 0; i < 12; i++ {
+		recipients = append(recipients, Recipient{Address: Address{Email: fmt.Sprintf("user%d@example.com", i)}})
+	}
+	// 4MB is 5.3MB in base64, 9 emails fit in 50MB
+	res, err := SendBatch(context.Background(), s, &Batch{
+		Message: Message{
+			From:        Address{Email: "john@example.com"},
+			Subject:     "report",
+			Text:        "attached",
+			Attachments: []Attachment{{FileName: "report.pdf", Content: make([]byte, 4*1000000)}},
+		},
+		Recipients: recipients,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Requests != 2 {
+		t.Errorf("got %d requests, want 2", res.Requests)
+	}
+	for _, req := range srv.Requests() {
+		if len(req.Body) > int(postmarkMaxBatchPayload) {
+			t.Errorf("sent %d bytes, over the payload limit", len(req.Body))
+		}
+	}
+}
+
+func TestMailgunBatchTemplateData(t *testing.T) {
+	srv := gomailertest.NewMailgun("key", "example.com")
+	defer srv.Close()
+	s, _ := NewSender(MAILGUN, Configs{APIKey: "key", Domain: "example.com", BaseURL: srv.BaseURL()})
+
+	_, err := SendBatch(context.Background(), s, &Batch{
+		Message: Message{
+			From:         Address{Email: "john@example.com"},
+			Template:     "welcome",
+			TemplateData: map[string]interface{}{"name": "friend", "plan": "free"},
+		},
+		Recipients: []Recipient{
+			{Address: Address{Email: "jane@example.com"}, Data: map[string]interface{}{"name": "Jane"}},
+			{Address: Address{Email: "tom@example.com"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := srv.Last().Form
+	vars := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(form["h:X-Mailgun-Variables"][0]), &vars); err != nil {
+		t.Fatal(err)
+	}
+	if vars["name"] != "%recipient.name%" || vars["plan"] != "free" {
+		t.Errorf("got template variables %v", vars)
+	}
+	recipientVars := map[string]map[string]interface{}{}
+	if err := json.Unmarshal([]byte(form["recipient-variables"][0]), &recipientVars); err != nil {
+		t.Fatal(err)
+	}
+	if got := recipientVars["jane@example.com"]["name"]; got != "Jane" {
+		t.Errorf("jane: got name %v, want Jane", got)
+	}
+	// a receipent without the key keeps the template value
+	if got := recipientVars["tom@example.com"]["name"]; got != "friend" {
+		t.Errorf("tom: got name %v, want friend", got)
+	}
+}
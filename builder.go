@@ -0,0 +1,315 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+type (
+	// builder implements the fluent Mailer, every send produces a new Message
+	// so nothing leaks from one send to the next. It is safe for concurrent
+	// use, the message is copied before it is handed to the sender
+	builder struct {
+		mu          sync.Mutex
+		sender      Sender
+		msg         Message
+		attachments []pendingAttachment
+		view        *view
+	}
+
+	// view describes the template rendered into the subject and bodies, with
+	// the data shared by every receipent and the data of single receipents
+	view struct {
+		templates *Templates
+		name      string
+		data      interface{}
+		receipent map[string]interface{}
+	}
+
+	// pendingAttachment describes an attachment added to the builder, files
+	// are read when the message is built, readers right away as they can only
+	// be consumed once
+	pendingAttachment struct {
+		path       string
+		inline     bool
+		attachment Attachment
+		err        error
+	}
+)
+
+// From sets an email sender address
+func (b *builder) From(name, from string) Mailer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msg.From = Address{Name: name, Email: from}
+	return b
+}
+
+// To sets receipents of an email
+func (b *builder) To(name, to string) Mailer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msg.To = append(b.msg.To, Address{Name: name, Email: to})
+	return b
+}
+
+// Cc sets Cc receipents of an email
+func (b *builder) Cc(name, to string) Mailer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msg.Cc = append(b.msg.Cc, Address{Name: name, Email: to})
+	return b
+}
+
+// Bcc sets Bcc receipents of an email
+func (b *builder) Bcc(name, to string) Mailer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msg.Bcc = append(b.msg.Bcc, Address{Name: name, Email: to})
+	return b
+}
+
+// ReplyTo sets the reply-to address of an email
+func (b *builder) ReplyTo(name, email string) Mailer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msg.ReplyTo = Address{Name: name, Email: email}
+	return b
+}
+
+// Subject sets subject of an email
+func (b *builder) Subject(subject string) Mailer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msg.Subject = subject
+	return b
+}
+
+// BodyHTML sets html body for an email
+func (b *builder) BodyHTML(body string) Mailer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msg.HTML = body
+	return b
+}
+
+// BodyText sets plain text email body for an email
+func (b *builder) BodyText(body string) Mailer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msg.Text = body
+	return b
+}
+
+// Tag adds a tag to an email
+func (b *builder) Tag(tag string) Mailer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msg.Tags = append(b.msg.Tags, tag)
+	return b
+}
+
+// ID sets a stable identity of the email
+func (b *builder) ID(id string) Mailer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msg.ID = id
+	return b
+}
+
+// Template sets a template hosted by the provider and its variables, the
+// provider renders the subject and bodies
+func (b *builder) Template(id string, data map[string]interface{}) Mailer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msg.Template = id
+	b.msg.TemplateData = data
+	return b
+}
+
+// Render sets the template rendering the subject and bodies with data, it is
+// rendered when the message is built
+func (b *builder) Render(t *Templates, name string, data interface{}) Mailer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rd := map[string]interface{}{}
+	if b.view != nil {
+		rd = b.view.receipent
+	}
+	b.view = &view{templates: t, name: name, data: data, receipent: rd}
+	return b
+}
+
+// RenderFor sets the template data of a To receipent, each one with data gets
+// its own email
+func (b *builder) RenderFor(email string, data interface{}) Mailer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.view == nil {
+		b.view = &view{receipent: map[string]interface{}{}}
+	}
+	b.view.receipent[email] = data
+	return b
+}
+
+// AttachmentFile set email attachments
+func (b *builder) AttachmentFile(file string) Mailer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.attachments = append(b.attachments, pendingAttachment{path: file})
+	return b
+}
+
+// AttachmentInlineFile set email inline attachment
+func (b *builder) AttachmentInlineFile(file string) Mailer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.attachments = append(b.attachments, pendingAttachment{path: file, inline: true})
+	return b
+}
+
+// AttachmentReader set email attachments
+func (b *builder) AttachmentReader(file string, r io.Reader) Mailer {
+	a, err := NewAttachment(file, r)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.attachments = append(b.attachments, pendingAttachment{attachment: a, err: err})
+	return b
+}
+
+// AttachmentInlineReader set email inline attachment
+func (b *builder) AttachmentInlineReader(file string, r io.Reader) Mailer {
+	a, err := NewAttachment(file, r)
+	a.Inline = true
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.attachments = append(b.attachments, pendingAttachment{attachment: a, inline: true, err: err})
+	return b
+}
+
+// Message return the message built so far, a template is rendered with the
+// shared data
+func (b *builder) Message() (*Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, err := b.build()
+	if err != nil {
+		return nil, err
+	}
+	if b.view != nil && b.view.templates != nil {
+		if err := b.view.templates.Render(m, b.view.name, b.view.data); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Messages return the messages to send, one per To receipent when receipents
+// have their own template data, otherwise the single message
+func (b *builder) Messages() ([]*Message, error) {
+	b.mu.Lock()
+	if b.view == nil || len(b.view.receipent) <= 0 {
+		b.mu.Unlock()
+		m, err := b.Message()
+		if err != nil {
+			return nil, err
+		}
+		return []*Message{m}, nil
+	}
+	defer b.mu.Unlock()
+	if b.view.templates == nil {
+		return nil, fmt.Errorf("gomailer: receipent template data set without a template")
+	}
+	if len(b.msg.Cc) > 0 || len(b.msg.Bcc) > 0 {
+		// every copy would reach the cc and bcc receipents
+		return nil, fmt.Errorf("gomailer: cc and bcc can not be combined with receipent template data")
+	}
+	to := map[string]bool{}
+	for _, a := range b.msg.To {
+		to[a.Email] = true
+	}
+	for email := range b.view.receipent {
+		if !to[email] {
+			return nil, fmt.Errorf("gomailer: template data for %s who is not a To receipent", email)
+		}
+	}
+	base, err := b.build()
+	if err != nil {
+		return nil, err
+	}
+	msgs := []*Message{}
+	for _, a := range base.To {
+		data, ok := b.view.receipent[a.Email]
+		if !ok {
+			data = b.view.data
+		}
+		m := base.clone()
+		m.To = []Address{a}
+		if err := b.view.templates.Render(m, b.view.name, data); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+// build return a copy of the message with the attachments read, the caller holds the lock
+func (b *builder) build() (*Message, error) {
+	m := b.msg.clone()
+	for _, p := range b.attachments {
+		if p.err != nil {
+			return nil, p.err
+		}
+		a := p.attachment
+		if p.path != "" {
+			var err error
+			if a, err = NewAttachmentFile(p.path); err != nil {
+				return nil, err
+			}
+			a.Inline = p.inline
+		}
+		m.Attachments = append(m.Attachments, a)
+	}
+	return m, nil
+}
+
+// Send process an email sending
+func (b *builder) Send() error {
+	return b.SendContext(context.Background())
+}
+
+// SendContext process an email sending, the context controls the request lifetime
+func (b *builder) SendContext(ctx context.Context) error {
+	_, err := b.SendWithResult(ctx)
+	return err
+}
+
+// SendWithResult process an email sending and return the provider response,
+// the results of receipents with their own template data are merged
+func (b *builder) SendWithResult(ctx context.Context) (*SendResult, error) {
+	msgs, err := b.Messages()
+	if err != nil {
+		return nil, err
+	}
+	var result *SendResult
+	for _, m := range msgs {
+		r, err := b.sender.Send(ctx, m)
+		result = result.merge(r)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// SendBatch process a batch sending, every receipent gets the message with
+// their own data. The builder must have no To, Cc or Bcc receipent
+func (b *builder) SendBatch(ctx context.Context, recipients ...Recipient) (*BatchResult, error) {
+	m, err := b.Message()
+	if err != nil {
+		return nil, err
+	}
+	return SendBatch(ctx, b.sender, &Batch{Message: *m, Recipients: recipients})
+}
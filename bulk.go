@@ -0,0 +1,277 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBulkWorkers describes the concurrent sends of a bulk sender
+	defaultBulkWorkers = 8
+	// defaultBulkDrain describes how long the sends in flight may take once the context is done
+	defaultBulkDrain = 30 * time.Second
+)
+
+type (
+	// BulkSender describes a sender which delivers a stream of messages with
+	// a bounded pool of workers. A message with more receipents than the
+	// driver allows per email is split into chunks, the Cc and Bcc receipents
+	// go with the first chunk only
+	BulkSender struct {
+		sender    Sender
+		workers   int
+		chunkSize int
+		drain     time.Duration
+		onResult  func(r BulkResult, p BulkProgress)
+	}
+
+	// BulkResult describes the outcome of a message of a bulk send
+	BulkResult struct {
+		Index   int         // Index represents the position of the message in the stream
+		Message *Message    // Message represents the message as received
+		Result  *SendResult // Result represents the merged result of the chunks sent so far
+		Chunks  int         // Chunks represents the number of emails the message was split into
+		Err     error       // Err represents the first failure, nil if every chunk was sent
+	}
+
+	// BulkProgress describes the progress of a bulk send
+	BulkProgress struct {
+		Done       int // Done represents the messages handled, sent or failed
+		Failed     int // Failed represents the messages with an error
+		Recipients int // Recipients represents the receipents of the sent chunks
+	}
+
+	// receipentLimiter describes a sender with a max number of receipents per email
+	receipentLimiter interface {
+		maxReceipents() int
+	}
+
+	// bulkJob describes a message waiting for a worker
+	bulkJob struct {
+		index int
+		msg   *Message
+	}
+
+	// detachedContext describes a context keeping the values of its parent
+	// without its deadline and cancellation
+	detachedContext struct {
+		parent context.Context
+	}
+)
+
+// NewBulkSender return a bulk sender delivering through s, the chunk size
+// defaults to the receipent limit of the driver
+func NewBulkSender(s Sender) *BulkSender {
+	return &BulkSender{sender: s, workers: defaultBulkWorkers, drain: defaultBulkDrain}
+}
+
+// Workers sets the number of concurrent sends, n <= 0 means 1
+func (b *BulkSender) Workers(n int) *BulkSender {
+	if n <= 0 {
+		n = 1
+	}
+	b.workers = n
+	return b
+}
+
+// ChunkSize sets the max receipents per email, for senders such as a
+// Failover whose driver limit is unknown. The lower of n and the driver
+// limit applies, n <= 0 means the driver limit only
+func (b *BulkSender) ChunkSize(n int) *BulkSender {
+	b.chunkSize = n
+	return b
+}
+
+// Drain sets how long the sends in flight may take once the context of Run
+// is done, 30s by default. d <= 0 cancels them along with the context
+func (b *BulkSender) Drain(d time.Duration) *BulkSender {
+	b.drain = d
+	return b
+}
+
+// OnResult sets the callback receiving every message outcome along with the
+// progress so far, calls never overlap so fn needs no locking
+func (b *BulkSender) OnResult(fn func(r BulkResult, p BulkProgress)) *BulkSender {
+	b.onResult = fn
+	return b
+}
+
+// Run send every message of the channel until it is closed. When ctx is
+// done no more messages or chunks are sent, the sends in flight get the
+// drain timeout to finish and the context error is returned
+func (b *BulkSender) Run(ctx context.Context, msgs <-chan *Message) (BulkProgress, error) {
+	return b.RunIterator(ctx, func() (*Message, bool) {
+		select {
+		case m, ok := <-msgs:
+			return m, ok
+		case <-ctx.Done():
+			return nil, false
+		}
+	})
+}
+
+// RunIterator send every message returned by next until it reports false,
+// cancellation works as for Run
+func (b *BulkSender) RunIterator(ctx context.Context, next func() (*Message, bool)) (BulkProgress, error) {
+	var (
+		mu       sync.Mutex
+		progress BulkProgress
+		wg       sync.WaitGroup
+	)
+	report := func(r BulkResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress.Done++
+		if r.Err != nil {
+			progress.Failed++
+		}
+		if r.Result != nil {
+			progress.Recipients += len(r.Result.Accepted)
+		}
+		if b.onResult != nil {
+			b.onResult(r, progress)
+		}
+	}
+
+	jobs := make(chan bulkJob)
+	for i := 0; i < b.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				report(b.send(ctx, j))
+			}
+		}()
+	}
+
+	for index := 0; ctx.Err() == nil; index++ {
+		m, ok := next()
+		if !ok {
+			break
+		}
+		select {
+		case jobs <- bulkJob{index: index, msg: m}:
+		case <-ctx.Done():
+			// taken from the stream but never sent
+			report(BulkResult{Index: index, Message: m, Err: ctx.Err()})
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	return progress, ctx.Err()
+}
+
+// send deliver the chunks of a message in order, stopping at the first failure
+func (b *BulkSender) send(ctx context.Context, j bulkJob) BulkResult {
+	r := BulkResult{Index: j.index, Message: j.msg}
+	if j.msg == nil {
+		r.Err = fmt.Errorf("gomailer: nil message at index %d", j.index)
+		return r
+	}
+	chunks, err := b.split(j.msg)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Chunks = len(chunks)
+	for _, c := range chunks {
+		if err := ctx.Err(); err != nil {
+			r.Err = err
+			return r
+		}
+		sctx, cancel := b.sendContext(ctx)
+		res, err := b.sender.Send(sctx, c)
+		cancel()
+		r.Result = r.Result.merge(res)
+		if err != nil {
+			r.Err = err
+			return r
+		}
+	}
+	return r
+}
+
+// sendContext return the context of a send, it outlives ctx by the drain
+// timeout so a send in flight is not cut short by the cancellation
+func (b *BulkSender) sendContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.drain <= 0 {
+		return context.WithCancel(ctx)
+	}
+	sctx, cancel := context.WithCancel(detachedContext{parent: ctx})
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+			return
+		}
+		t := time.NewTimer(b.drain)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			cancel()
+		case <-stop:
+		}
+	}()
+	return sctx, func() {
+		close(stop)
+		cancel()
+	}
+}
+
+// Deadline return no deadline, the parent one is dropped
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// Done return nil, a detached context is never cancelled
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+// Err return nil, a detached context is never cancelled
+func (detachedContext) Err() error {
+	return nil
+}
+
+// Value return the value of the parent context
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// split return the emails of a message within the receipent limit
+func (b *BulkSender) split(m *Message) ([]*Message, error) {
+	limit := b.chunkSize
+	if l, ok := b.sender.(receipentLimiter); ok && (limit <= 0 || l.maxReceipents() < limit) {
+		limit = l.maxReceipents()
+	}
+	if limit <= 0 || m.receipents() <= limit {
+		return []*Message{m}, nil
+	}
+
+	// the first chunk carries cc and bcc, the others to receipents only
+	first := limit - len(m.Cc) - len(m.Bcc)
+	if first <= 0 {
+		return nil, fmt.Errorf("%w: %d cc and bcc receipents leave no room for a to receipent within the limit of %d", ErrTooManyRecipients, len(m.Cc)+len(m.Bcc), limit)
+	}
+	chunks := []*Message{}
+	to, size := m.To, first
+	for len(to) > 0 {
+		if size > len(to) {
+			size = len(to)
+		}
+		c := m.clone()
+		c.To = append([]Address(nil), to[:size]...)
+		if len(chunks) > 0 {
+			c.Cc, c.Bcc = nil, nil
+		}
+		chunks = append(chunks, c)
+		to, size = to[size:], limit
+	}
+	return chunks, nil
+}
@@ -0,0 +1,64 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/thedevsaddam/gomailer/gomailertest"
+)
+
+func TestBulkDrain(t *testing.T) {
+	tests := []struct {
+		name  string
+		drain time.Duration
+		sent  bool
+	}{
+		{"in flight send finishes", time.Second, true},
+		{"drain timeout cancels", 10 * time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := gomailertest.NewSendGrid("key")
+			defer srv.Close()
+			srv.Slow(200 * time.Millisecond)
+			s, _ := NewSender(SENDGRID, Configs{APIKey: "key", BaseURL: srv.BaseURL()})
+
+			msgs := make(chan *Message, 2)
+			for i := 0; i < 2; i++ {
+				msgs <- &Message{
+					From:    Address{Email: "john@example.com"},
+					To:      []Address{{Email: "jane@example.com"}},
+					Subject: "subject",
+					Text:    "text",
+				}
+			}
+			close(msgs)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			results := map[int]BulkResult{}
+			progress, err := NewBulkSender(s).Workers(1).Drain(tt.drain).OnResult(func(r BulkResult, p BulkProgress) {
+				results[r.Index] = r
+			}).Run(ctx, msgs)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("got %v, want context.Canceled", err)
+			}
+			first, ok := results[0]
+			if !ok {
+				t.Fatal("the send in flight was not reported")
+			}
+			if sent := first.Err == nil; sent != tt.sent {
+				t.Errorf("in flight send: got error %v", first.Err)
+			}
+			// the second message is not sent after the cancellation
+			if n := len(srv.Requests()); n != 1 {
+				t.Errorf("got %d requests, want 1", n)
+			}
+			if progress.Done != len(results) {
+				t.Errorf("progress reports %d done, %d results", progress.Done, len(results))
+			}
+		})
+	}
+}
@@ -2,32 +2,104 @@ package gomailer
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	// dialTimeout describes the max time to establish a connection
+	dialTimeout = 30 * time.Second
+	// tlsHandshakeTimeout describes the max time to wait for a tls handshake
+	tlsHandshakeTimeout = 10 * time.Second
+	// idleConnTimeout describes how long an idle keep-alive connection stays in the pool
+	idleConnTimeout = 90 * time.Second
+	// maxIdleConnsPerHost describes the idle connections kept per host, the
+	// net/http default of 2 forces a new socket for almost every concurrent send
+	maxIdleConnsPerHost = 100
+)
+
+var (
+	// transports holds one pooled transport per driver, shared by every
+	// instance so creating a mailer per email does not open new sockets
+	transports   = map[driver]*http.Transport{}
+	transportsMu sync.Mutex
+)
+
+// client describes the http client of a driver, it is safe for concurrent use
 type client struct {
-	timeOut time.Duration
+	timeOut    time.Duration
+	httpClient *http.Client
+	limiter    *limiter
 }
 
-// getDefaultClient return a default http client
-func (c *client) getDefaultClient() *http.Client {
-	if c.timeOut == 0 {
-		c.timeOut = defaultTimeout
+// newClient return a client using the http client or transport from the
+// config, falling back to the shared transport of the driver
+func newClient(d driver, c Configs) client {
+	timeOut := c.RequestTimeout
+	if timeOut == 0 {
+		timeOut = defaultTimeout
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		transport := c.Transport
+		if transport == nil {
+			transport = sharedTransport(d)
+		}
+		httpClient = &http.Client{
+			Timeout:   timeOut,
+			Transport: transport,
+		}
 	}
-	netTransport := &http.Transport{
-		Dial: (&net.Dialer{
-			Timeout: c.timeOut,
-		}).Dial,
-		TLSHandshakeTimeout: c.timeOut * time.Second,
+	if c.Retry.enabled() {
+		// copy the client so the one from the config is left untouched
+		hc := *httpClient
+		next := hc.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		hc.Transport = &retryTransport{next: next, policy: c.Retry}
+		httpClient = &hc
 	}
+	return client{
+		timeOut:    timeOut,
+		httpClient: httpClient,
+		limiter:    sharedLimiter(d, c),
+	}
+}
 
-	return &http.Client{
-		Timeout:   c.timeOut,
-		Transport: netTransport,
+// sharedTransport return the pooled transport of a driver
+func sharedTransport(d driver) *http.Transport {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if t, ok := transports[d]; ok {
+		return t
 	}
+	t := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: tlsHandshakeTimeout,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        maxIdleConnsPerHost,
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		IdleConnTimeout:     idleConnTimeout,
+	}
+	transports[d] = t
+	return t
+}
+
+// getDefaultClient return the http client of the driver
+func (c client) getDefaultClient() *http.Client {
+	return c.httpClient
 }
 
 // toJSON encode data to json and return bytes
@@ -38,3 +110,25 @@ func toJSON(t interface{}) ([]byte, error) {
 	err := encoder.Encode(t)
 	return buffer.Bytes(), err
 }
+
+// postForm perform a post request with content type application/x-www-form-urlencoded
+// and return the status code along with the raw response body
+func (c client) postForm(ctx context.Context, endpoint string, values url.Values) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return 0, nil, newLocalError("", err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.getDefaultClient().Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
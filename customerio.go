@@ -3,9 +3,8 @@ package gomailer
 import (
 	"context"
 	"errors"
-	"fmt"
-	"io"
-	"os"
+	"html"
+	"net/http"
 	"strings"
 
 	cio "github.com/customerio/go-customerio/v3"
@@ -23,21 +22,8 @@ type (
 
 	// customerio describes a customerio type
 	customerio struct {
-		c                       client
-		configs                 Configs
-		from                    address
-		toList                  []address
-		ccList                  []address
-		bccList                 []address
-		replyTo                 address
-		subject                 string
-		bodyHTML                string
-		bodyText                string
-		attachmentFiles         []string
-		attachmentInlineFiles   []string
-		attachmentReaders       map[string]readerInfo
-		attachmentInlineReaders map[string]readerInfo
-		attachments             []attachment
+		c       client
+		configs Configs
 	}
 
 	customerioContent struct {
@@ -46,191 +32,97 @@ type (
 	}
 )
 
-// From sets an email sender address
-func (c *customerio) From(name, from string) Mailer {
-	c.from = address{Name: name, Email: from}
-	return c
-}
-
-// To sets receipents of an email
-func (c *customerio) To(name, to string) Mailer {
-	c.toList = append(c.toList, address{Name: name, Email: to})
-	return c
-}
-
-// Cc sets Cc receipents of an email
-func (c *customerio) Cc(name, to string) Mailer {
-	c.ccList = append(c.ccList, address{Name: name, Email: to})
-	return c
-}
-
-// Bcc sets Bcc receipents of an email
-func (c *customerio) Bcc(name, to string) Mailer {
-	c.bccList = append(c.bccList, address{Name: name, Email: to})
-	return c
-}
-
-// ReplyTo sets the reply-to address of an email
-func (c *customerio) ReplyTo(name, email string) Mailer {
-	c.replyTo = address{Name: name, Email: email}
-	return c
-}
-
-// Subject sets subject of an email
-func (c *customerio) Subject(subject string) Mailer {
-	c.subject = subject
-	return c
-}
-
-// BodyHTML sets html body for an email
-func (c *customerio) BodyHTML(body string) Mailer {
-	c.bodyHTML = body
-	return c
-}
-
-// BodyText sets plain text email body for an email
-func (c *customerio) BodyText(body string) Mailer {
-	c.bodyText = body
-	return c
-}
-
-// AttachmentFile set email attachments
-func (c *customerio) AttachmentFile(file string) Mailer {
-	c.attachmentFiles = append(c.attachmentFiles, file)
-	return c
-}
-
-// AttachmentInlineFile set email inline attachment
-func (c *customerio) AttachmentInlineFile(file string) Mailer {
-	c.attachmentInlineFiles = append(c.attachmentInlineFiles, file)
-	return c
-}
-
-// AttachmentReader set email attachments
-func (c *customerio) AttachmentReader(file string, r io.Reader) Mailer {
-	c.attachmentReaders = make(map[string]readerInfo)
-	c.attachmentReaders[file] = readerInfo{r: r}
-	return c
-}
-
-// AttachmentInlineReader set email inline attachment
-func (c *customerio) AttachmentInlineReader(file string, r io.Reader) Mailer {
-	c.attachmentInlineReaders = make(map[string]readerInfo)
-	c.attachmentInlineReaders[file] = readerInfo{r: r}
-	return c
-}
-
-// Send process an email sending
-func (c *customerio) Send() error {
+// Send process an email sending and return the provider response
+func (c *customerio) Send(ctx context.Context, msg *Message) (*SendResult, error) {
 	// verify params for sending email
-	c.verifyParams()
-
-	//check the total file size and path
-	var totalSize int64
-	for _, f := range c.attachmentFiles {
-		fi, e := os.Stat(f)
-		if e != nil {
-			return e
-		}
-		// get the size
-		totalSize += fi.Size()
-	}
-
-	if totalSize > customerioMaxFileSize {
-		return errors.New("gomailer: max attachment size for customerio is 30MB")
+	if err := c.verifyParams(msg); err != nil {
+		return nil, err
 	}
 
-	// build attachment
-	for _, f := range c.attachmentFiles {
-		a := attachment{}
-		err := a.ReadFromFile(f)
-		if err != nil {
-			return err
-		}
-		a.Disposition = "attachment"
-		c.attachments = append(c.attachments, a)
+	// the send email api has neither cc nor inline files
+	features := []string{}
+	if len(msg.Cc) > 0 {
+		features = append(features, "Cc")
 	}
-
-	// build attachment
-	for f, r := range c.attachmentReaders {
-		a := attachment{}
-		err := a.ReadFromReader(f, r)
-		if err != nil {
-			return err
-		}
-		a.Disposition = "attachment"
-		c.attachments = append(c.attachments, a)
+	if msg.hasAttachments(true) {
+		features = append(features, "inline attachments")
 	}
-
-	// build attachment inine
-	for _, f := range c.attachmentInlineFiles {
-		a := attachment{}
-		err := a.ReadFromFile(f)
-		if err != nil {
-			return err
-		}
-		a.Disposition = "inline"
-		c.attachments = append(c.attachments, a)
+	if len(features) > 0 {
+		return nil, &unsupportedError{service: "customerio", features: features}
 	}
 
-	// build attachment inine
-	for f, r := range c.attachmentInlineReaders {
-		a := attachment{}
-		err := a.ReadFromReader(f, r)
-		if err != nil {
-			return err
-		}
-		a.Disposition = "inline"
-		c.attachments = append(c.attachments, a)
-	}
 	req := cio.SendEmailRequest{
-		From:    c.from.format(),
-		To:      c.lists(c.toList),
-		Subject: c.subject,
+		From:    msg.From.format(),
+		To:      c.lists(msg.To),
+		Subject: msg.Subject,
 
 		Identifiers: map[string]string{
 			"id": uuid.New().String(),
 		},
 	}
 
-	if len(c.ccList) > 0 {
-		req.BCC = c.lists(c.ccList)
+	if len(msg.Bcc) > 0 {
+		req.BCC = c.lists(msg.Bcc)
 	}
 
-	if len(c.bccList) > 0 {
-		req.BCC = c.lists(c.bccList)
+	if msg.ReplyTo.Email != "" {
+		req.ReplyTo = msg.ReplyTo.format()
 	}
 
-	if c.replyTo.Email != "" {
-		req.ReplyTo = c.replyTo.format()
+	if msg.Text != "" {
+		req.PlaintextBody = msg.Text
 	}
 
-	if c.bodyText != "" {
-		req.PlaintextBody = c.bodyText
+	if msg.HTML != "" {
+		req.Body = msg.HTML
+	} else if msg.Template == "" {
+		// the body is required without a transactional message
+		req.Body = "<pre>" + html.EscapeString(msg.Text) + "</pre>"
 	}
 
-	if c.bodyHTML != "" {
-		req.Body = c.bodyHTML
+	// transactional messages are rendered by customer.io with the message data
+	if msg.Template != "" {
+		req.TransactionalMessageID = msg.Template
+		req.MessageData = msg.TemplateData
 	}
 
-	if len(c.attachments) > 0 {
+	if len(msg.Attachments) > 0 {
 		files := map[string]string{}
-		for _, a := range c.attachments {
-			files[a.FileName] = a.Content
+		for _, a := range msg.Attachments {
+			files[a.FileName] = a.encode().Content
 		}
 		req.Attachments = files
 	}
 
-	ctx := context.Background()
-	client := cio.NewAPIClient(c.configs.APIKey, cio.WithRegion(cio.RegionUS))
-	if _, err := client.SendEmail(ctx, &req); err != nil {
-		return err
+	client := cio.NewAPIClient(c.configs.APIKey, cio.WithRegion(cio.RegionUS), cio.WithHTTPClient(c.c.getDefaultClient()))
+	if c.configs.BaseURL != "" {
+		client.URL = c.configs.BaseURL
+	}
+
+	// wait for the client side rate limiter if any
+	ctx, err := c.c.limiter.wait(ctx, "customerio", msg.receipents())
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	resp, err := client.SendEmail(ctx, &req)
+	if err != nil {
+		var terr *cio.TransactionalError
+		if errors.As(err, &terr) {
+			return nil, newProviderError("customerio", terr.StatusCode, nil, "", terr.Err)
+		}
+		return nil, err
+	}
+
+	// the sdk does not expose the raw response
+	r := newSendResult("customerio", http.StatusOK, nil, msg.To, msg.Cc, msg.Bcc)
+	if resp.DeliveryID != "" {
+		r.MessageIDs = []string{resp.DeliveryID}
+	}
+	return r, nil
 }
 
-func (c customerio) lists(a []address) string {
+func (c customerio) lists(a []Address) string {
 	if len(a) <= 0 {
 		return ""
 	}
@@ -241,18 +133,25 @@ func (c customerio) lists(a []address) string {
 	return strings.Join(list, ",")
 }
 
+// maxAttachmentSize return the max total size in bytes of the attachments
+func (customerio) maxAttachmentSize() int64 {
+	return customerioMaxFileSize
+}
+
+// maxReceipents return the max to, cc and bcc receipents per email
+func (customerio) maxReceipents() int {
+	return customerioMaxReceipents
+}
+
 // verifyParams verify the required params
-func (c customerio) verifyParams() {
-	if c.from.Email == "" {
-		panic("gomailer: you must provide from")
-	}
-	if len(c.toList) <= 0 {
-		panic("gomailer: you must provide at least one receipent")
-	}
-	if len(c.toList)+len(c.ccList)+len(c.bccList) > customerioMaxReceipents {
-		panic(fmt.Sprintf("mailer: total number of receipents including to/cc/bcc can not be greater than %d for customerio", customerioMaxReceipents))
-	}
-	if c.bodyText == "" && c.bodyHTML == "" {
-		panic("gomailer: you must provide a Text or HTML body")
-	}
+func (c customerio) verifyParams(msg *Message) error {
+	v := validation{service: "customerio"}
+	if c.configs.APIKey == "" {
+		v.add(ErrMissingCredentials, "for customerio you must provide APIKey in config")
+	}
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), customerioMaxReceipents)
+	v.verifyBody(msg)
+	v.verifyAttachments(msg.attachmentSize(), customerioMaxFileSize)
+	return v.err()
 }
@@ -0,0 +1,247 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/thedevsaddam/gomailer/gomailertest"
+)
+
+// fakeDriver describes a driver tested against a gomailertest server
+type fakeDriver struct {
+	name     string
+	driver   driver
+	server   func() *gomailertest.Server
+	configs  func(baseURL string) Configs
+	template string
+}
+
+var fakeDrivers = []fakeDriver{
+	{
+		name:     "mailgun",
+		driver:   MAILGUN,
+		server:   func() *gomailertest.Server { return gomailertest.NewMailgun("key", "example.com") },
+		configs:  func(u string) Configs { return Configs{APIKey: "key", Domain: "example.com", BaseURL: u} },
+		template: "welcome",
+	},
+	{
+		name:     "sendgrid",
+		driver:   SENDGRID,
+		server:   func() *gomailertest.Server { return gomailertest.NewSendGrid("key") },
+		configs:  func(u string) Configs { return Configs{APIKey: "key", BaseURL: u} },
+		template: "d-welcome",
+	},
+	{
+		name:     "postmark",
+		driver:   POSTMARK,
+		server:   func() *gomailertest.Server { return gomailertest.NewPostmark("token") },
+		configs:  func(u string) Configs { return Configs{ServerToken: "token", BaseURL: u} },
+		template: "welcome",
+	},
+	{
+		name:     "mailjet",
+		driver:   MAILJET,
+		server:   func() *gomailertest.Server { return gomailertest.NewMailjet("pub", "priv") },
+		configs:  func(u string) Configs { return Configs{PublicKey: "pub", PrivateKey: "priv", BaseURL: u} },
+		template: "1234",
+	},
+	{
+		name:     "customerio",
+		driver:   CUSTOMERIO,
+		server:   func() *gomailertest.Server { return gomailertest.NewCustomerIO("key") },
+		configs:  func(u string) Configs { return Configs{APIKey: "key", BaseURL: u} },
+		template: "7",
+	},
+}
+
+// fakeSender start the fake server of a driver and return a sender pointing at it
+func fakeSender(t *testing.T, d fakeDriver, c func(*Configs)) (Sender, *gomailertest.Server) {
+	t.Helper()
+	srv := d.server()
+	t.Cleanup(srv.Close)
+	configs := d.configs(srv.BaseURL())
+	if c != nil {
+		c(&configs)
+	}
+	s, err := NewSender(d.driver, configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, srv
+}
+
+func TestDriversSend(t *testing.T) {
+	messages := map[string]*Message{
+		"full": {
+			From:        Address{Name: "John", Email: "john@example.com"},
+			To:          []Address{{Name: "Jane", Email: "jane@example.com"}, {Email: "tom@example.com"}},
+			Cc:          []Address{{Email: "cc@example.com"}},
+			Bcc:         []Address{{Email: "bcc@example.com"}},
+			ReplyTo:     Address{Email: "reply@example.com"},
+			Subject:     "subject",
+			Text:        "text",
+			HTML:        "<p>html</p>",
+			Attachments: []Attachment{{FileName: "a.txt", Content: []byte("attached")}},
+		},
+		"text": {
+			From:    Address{Email: "john@example.com"},
+			To:      []Address{{Email: "jane@example.com"}},
+			Subject: "subject",
+			Text:    "text",
+		},
+		"html": {
+			From:    Address{Email: "john@example.com"},
+			To:      []Address{{Email: "jane@example.com"}},
+			Subject: "subject",
+			HTML:    "<p>html</p>",
+		},
+	}
+	for _, d := range fakeDrivers {
+		for name, msg := range messages {
+			t.Run(d.name+"/"+name, func(t *testing.T) {
+				s, srv := fakeSender(t, d, nil)
+				msg := msg.clone()
+				if d.driver == CUSTOMERIO {
+					// customer.io has no cc
+					msg.Cc = nil
+				}
+				res, err := s.Send(context.Background(), msg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.MessageID() == "" {
+					t.Error("no message id")
+				}
+				if n := len(srv.Requests()); n != 1 {
+					t.Errorf("got %d requests, want 1", n)
+				}
+			})
+		}
+	}
+}
+
+func TestDriversTemplate(t *testing.T) {
+	for _, d := range fakeDrivers {
+		t.Run(d.name, func(t *testing.T) {
+			s, srv := fakeSender(t, d, nil)
+			_, err := s.Send(context.Background(), &Message{
+				From:         Address{Email: "john@example.com"},
+				To:           []Address{{Email: "jane@example.com"}},
+				Template:     d.template,
+				TemplateData: map[string]interface{}{"name": "Jane"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if srv.Last() == nil {
+				t.Error("no request received")
+			}
+		})
+	}
+}
+
+func TestDriversBatch(t *testing.T) {
+	recipients := []Recipient{}
+	for i := 0; i < 3; i++ {
+		recipients = append(recipients, Recipient{
+			Address: Address{Email: fmt.Sprintf("user%d@example.com", i)},
+			Data:    map[string]interface{}{"name": fmt.Sprintf("user %d", i)},
+		})
+	}
+	for _, d := range fakeDrivers {
+		t.Run(d.name, func(t *testing.T) {
+			s, srv := fakeSender(t, d, nil)
+			res, err := SendBatch(context.Background(), s, &Batch{
+				Message: Message{
+					From:    Address{Email: "john@example.com"},
+					Subject: "hello {{name}}",
+					Text:    "hello {{name}}",
+				},
+				Recipients: recipients,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Recipients) != len(recipients) {
+				t.Fatalf("got %d results, want %d", len(res.Recipients), len(recipients))
+			}
+			for i, r := range res.Recipients {
+				if r.Err != nil || r.Email != recipients[i].Address.Email {
+					t.Errorf("recipient %d: got %s %v", i, r.Email, r.Err)
+				}
+			}
+			if n := len(srv.Requests()); n != res.Requests {
+				t.Errorf("got %d requests, the result reports %d", n, res.Requests)
+			}
+		})
+	}
+}
+
+func TestDriversProviderError(t *testing.T) {
+	for _, d := range fakeDrivers {
+		t.Run(d.name, func(t *testing.T) {
+			s, srv := fakeSender(t, d, nil)
+			srv.Fail(http.StatusServiceUnavailable, "down for maintenance")
+			_, err := s.Send(context.Background(), &Message{
+				From:    Address{Email: "john@example.com"},
+				To:      []Address{{Email: "jane@example.com"}},
+				Subject: "subject",
+				Text:    "text",
+			})
+			var perr *ProviderError
+			if !errors.As(err, &perr) {
+				t.Fatalf("got %v, want a ProviderError", err)
+			}
+			if perr.StatusCode != http.StatusServiceUnavailable || !perr.Retryable() {
+				t.Errorf("got status %d retryable %v", perr.StatusCode, perr.Retryable())
+			}
+		})
+	}
+}
+
+func TestDriversThrottleRetry(t *testing.T) {
+	for _, d := range fakeDrivers {
+		t.Run(d.name, func(t *testing.T) {
+			s, srv := fakeSender(t, d, func(c *Configs) {
+				c.Retry = RetryPolicy{MaxAttempts: 2, BaseBackoff: time.Millisecond}
+			})
+			srv.Throttle(0)
+			_, err := s.Send(context.Background(), &Message{
+				From:    Address{Email: "john@example.com"},
+				To:      []Address{{Email: "jane@example.com"}},
+				Subject: "subject",
+				Text:    "text",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := len(srv.Requests()); n != 2 {
+				t.Errorf("got %d requests, want 2", n)
+			}
+		})
+	}
+}
+
+func TestDriversSlowResponse(t *testing.T) {
+	for _, d := range fakeDrivers {
+		t.Run(d.name, func(t *testing.T) {
+			s, srv := fakeSender(t, d, nil)
+			srv.Slow(time.Second)
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			_, err := s.Send(ctx, &Message{
+				From:    Address{Email: "john@example.com"},
+				To:      []Address{{Email: "jane@example.com"}},
+				Subject: "subject",
+				Text:    "text",
+			})
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("got %v, want context.DeadlineExceeded", err)
+			}
+		})
+	}
+}
@@ -1,3 +1,234 @@
 package gomailer
 
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
 // https://api.elasticemail.com/public/help
+// https://elasticemail.com/developers/api-documentation/rest-api#operation/emailsTransactionalPost
+
+const (
+	// elasticemailBaseURL describes elastic email mail sending api base url
+	elasticemailBaseURL = "https://api.elasticemail.com/v4"
+	// elasticemailMaxFileSize describes the max file size in bytes for sending per email for elastic email
+	elasticemailMaxFileSize int64 = 20 * 1000000
+	// elasticemailMaxReceipents describes the max receipents per email
+	elasticemailMaxReceipents = 100
+)
+
+type (
+	// elasticemail describes an elastic email type
+	elasticemail struct {
+		c       client
+		configs Configs
+	}
+
+	// elasticemailBody describes a body part of an email
+	elasticemailBody struct {
+		ContentType string `json:"ContentType"`
+		Content     string `json:"Content"`
+		Charset     string `json:"Charset"`
+	}
+
+	// elasticemailAttachment describes an email attachment
+	elasticemailAttachment struct {
+		BinaryContent string `json:"BinaryContent"`
+		Name          string `json:"Name"`
+		ContentType   string `json:"ContentType"`
+	}
+
+	// elasticemailResponse describes the possible error envelopes of elastic email
+	elasticemailResponse struct {
+		Success       *bool  `json:"success"`
+		Error         string `json:"error"`
+		V4Error       string `json:"Error"`
+		TransactionID string `json:"TransactionID"`
+		MessageID     string `json:"MessageID"`
+	}
+)
+
+// messageURL return a message url
+func (e *elasticemail) messageURL() string {
+	url := elasticemailBaseURL
+	if e.configs.BaseURL != "" {
+		url = e.configs.BaseURL
+	}
+	return fmt.Sprintf("%s/emails/transactional", url)
+}
+
+// Send process an email sending and return the provider response
+func (e *elasticemail) Send(ctx context.Context, msg *Message) (*SendResult, error) {
+	// verify params for sending email
+	if err := e.verifyParams(msg); err != nil {
+		return nil, err
+	}
+
+	// the v4 api attachments carry no content id, an inline file could not
+	// be referenced from the html body
+	if msg.hasAttachments(true) {
+		return nil, &unsupportedError{service: "elastic email", features: []string{"inline attachments"}}
+	}
+
+	// build attachment, the v4 api takes the file content as base64 in the
+	// same request
+	attachments := []elasticemailAttachment{}
+	for _, f := range msg.Attachments {
+		a := f.encode()
+		attachments = append(attachments, elasticemailAttachment{
+			BinaryContent: a.Content,
+			Name:          a.FileName,
+			ContentType:   a.Type,
+		})
+	}
+
+	// build params
+	recipients := map[string][]string{
+		"To": e.lists(msg.To),
+	}
+	if len(msg.Cc) > 0 {
+		recipients["CC"] = e.lists(msg.Cc)
+	}
+	if len(msg.Bcc) > 0 {
+		recipients["BCC"] = e.lists(msg.Bcc)
+	}
+
+	bodies := []elasticemailBody{}
+	if len(msg.HTML) > 0 {
+		bodies = append(bodies, elasticemailBody{
+			ContentType: "HTML",
+			Content:     msg.HTML,
+			Charset:     "utf-8",
+		})
+	}
+	if len(msg.Text) > 0 {
+		bodies = append(bodies, elasticemailBody{
+			ContentType: "PlainText",
+			Content:     msg.Text,
+			Charset:     "utf-8",
+		})
+	}
+
+	content := mapData{
+		"From": msg.From.format(),
+	}
+
+	// a template brings its own subject and bodies unless they are given
+	if msg.Template == "" || msg.Subject != "" {
+		content["Subject"] = msg.Subject
+	}
+	if msg.Template == "" || len(bodies) > 0 {
+		content["Body"] = bodies
+	}
+
+	// the data fills the {merge} fields of the template as text
+	if msg.Template != "" {
+		content["TemplateName"] = msg.Template
+		if len(msg.TemplateData) > 0 {
+			merge := map[string]string{}
+			for k, v := range msg.TemplateData {
+				merge[k] = fmt.Sprint(v)
+			}
+			content["Merge"] = merge
+		}
+	}
+
+	if msg.ReplyTo.Email != "" {
+		content["ReplyTo"] = msg.ReplyTo.format()
+	}
+
+	if len(attachments) > 0 {
+		content["Attachments"] = attachments
+	}
+
+	params := mapData{
+		"Recipients": recipients,
+		"Content":    content,
+	}
+
+	// wait for the client side rate limiter if any
+	ctx, err := e.c.limiter.wait(ctx, "elastic email", msg.receipents())
+	if err != nil {
+		return nil, err
+	}
+
+	return e.processElasticemailRequest(ctx, msg, params)
+}
+
+// lists return a list of formatted email
+func (elasticemail) lists(a []Address) []string {
+	list := []string{}
+	for _, v := range a {
+		list = append(list, v.format())
+	}
+	return list
+}
+
+// maxAttachmentSize return the max total size in bytes of the attachments
+func (elasticemail) maxAttachmentSize() int64 {
+	return elasticemailMaxFileSize
+}
+
+// maxReceipents return the max to, cc and bcc receipents per email
+func (elasticemail) maxReceipents() int {
+	return elasticemailMaxReceipents
+}
+
+// verifyParams verify the required params
+func (e elasticemail) verifyParams(msg *Message) error {
+	v := validation{service: "elastic email"}
+	if e.configs.APIKey == "" {
+		v.add(ErrMissingCredentials, "for elastic email you must provide APIKey in config")
+	}
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), elasticemailMaxReceipents)
+	v.verifyBody(msg)
+	v.verifyAttachments(msg.attachmentSize(), elasticemailMaxFileSize)
+	return v.err()
+}
+
+// processElasticemailRequest perform a post request with content type application/json for elastic email
+func (e *elasticemail) processElasticemailRequest(ctx context.Context, msg *Message, bodyParams map[string]interface{}) (*SendResult, error) {
+	body, err := toJSON(bodyParams)
+	if err != nil {
+		return nil, newLocalError("elastic email", err)
+	}
+	req, errReq := http.NewRequestWithContext(ctx, "POST", e.messageURL(), bytes.NewBuffer(body))
+	if errReq != nil {
+		return nil, newLocalError("elastic email", errReq)
+	}
+
+	req.Header.Add("X-ElasticEmail-ApiKey", e.configs.APIKey)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := e.c.getDefaultClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyByte, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// elastic email may report a failure with a 200 status
+	result := elasticemailResponse{}
+	_ = json.Unmarshal(bodyByte, &result)
+	if result.Success != nil && !*result.Success {
+		return nil, newProviderError("elastic email", resp.StatusCode, bodyByte, "", result.Error)
+	}
+	if resp.StatusCode != http.StatusOK || result.V4Error != "" {
+		return nil, newProviderError("elastic email", resp.StatusCode, bodyByte, "", result.V4Error)
+	}
+	r := newSendResult("elastic email", resp.StatusCode, bodyByte, msg.To, msg.Cc, msg.Bcc)
+	if result.MessageID != "" {
+		r.MessageIDs = []string{result.MessageID}
+	} else if result.TransactionID != "" {
+		r.MessageIDs = []string{result.TransactionID}
+	}
+	return r, nil
+}
@@ -0,0 +1,212 @@
+package gomailer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNoFrom is returned when an email has no sender address
+	ErrNoFrom = errors.New("gomailer: you must provide from")
+	// ErrNoRecipients is returned when an email has no To receipent
+	ErrNoRecipients = errors.New("gomailer: you must provide at least one receipent")
+	// ErrTooManyRecipients is returned when the to/cc/bcc receipents exceed the driver limit
+	ErrTooManyRecipients = errors.New("gomailer: too many receipents")
+	// ErrNoBody is returned when an email has neither a text nor an html body
+	ErrNoBody = errors.New("gomailer: you must provide a Text or HTML body")
+	// ErrAttachmentTooLarge is returned when the attachments exceed the driver limit
+	ErrAttachmentTooLarge = errors.New("gomailer: attachments too large")
+	// ErrMissingCredentials is returned when the config lacks the credentials of the driver
+	ErrMissingCredentials = errors.New("gomailer: missing credentials")
+	// ErrInvalidConfig is returned when a required config such as Domain or Host is missing or malformed
+	ErrInvalidConfig = errors.New("gomailer: invalid config")
+	// ErrUnsupported is returned when a driver can not deliver a feature of the email
+	ErrUnsupported = errors.New("gomailer: unsupported feature")
+	// ErrInvalidTemplate is returned when a hosted template id does not fit the provider format
+	ErrInvalidTemplate = errors.New("gomailer: invalid template")
+	// ErrCircuitOpen is returned when every sender of a failover is skipped by its circuit breaker
+	ErrCircuitOpen = errors.New("gomailer: every sender is unavailable, circuit open")
+	// ErrNoRoute is returned when no route of a router can take the message
+	ErrNoRoute = errors.New("gomailer: no route matches the message")
+	// ErrRateLimited is returned when waiting for the rate limiter would exceed the context deadline
+	ErrRateLimited = errors.New("gomailer: rate limited")
+	// ErrNotQueued is returned when a queue store has no item with the id
+	ErrNotQueued = errors.New("gomailer: message not found in the queue")
+)
+
+// ValidationError describes every rule an email violates before it is sent,
+// each violation wraps one of the exported sentinel errors
+type ValidationError struct {
+	Service    string
+	Violations []error
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	msgs := []string{}
+	for _, v := range e.Violations {
+		msgs = append(msgs, strings.TrimPrefix(v.Error(), "gomailer: "))
+	}
+	return fmt.Sprintf("gomailer: invalid email for %s: %s", e.Service, strings.Join(msgs, "; "))
+}
+
+// Is reports whether any violation matches target, so errors.Is(err, ErrNoRecipients) works
+func (e *ValidationError) Is(target error) bool {
+	for _, v := range e.Violations {
+		if errors.Is(v, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// validation collects the violated rules of an email
+type validation struct {
+	service    string
+	violations []error
+}
+
+// add record a violation, wrapping the sentinel with a detailed message
+func (v *validation) add(sentinel error, format string, args ...interface{}) {
+	if format == "" {
+		v.violations = append(v.violations, sentinel)
+		return
+	}
+	v.violations = append(v.violations, fmt.Errorf("%w: %s", sentinel, fmt.Sprintf(format, args...)))
+}
+
+// verifyFrom verify the sender address
+func (v *validation) verifyFrom(email string) {
+	if email == "" {
+		v.add(ErrNoFrom, "")
+	}
+}
+
+// verifyReceipents verify the number of receipents, max <= 0 means no limit
+func (v *validation) verifyReceipents(to, total, max int) {
+	if to <= 0 {
+		v.add(ErrNoRecipients, "")
+	}
+	if max > 0 && total > max {
+		v.add(ErrTooManyRecipients, "total number of receipents including to/cc/bcc can not be greater than %d for %s", max, v.service)
+	}
+}
+
+// verifyBody verify that at least one body exists, a hosted template renders its own
+func (v *validation) verifyBody(msg *Message) {
+	if msg.Template == "" && msg.Text == "" && msg.HTML == "" {
+		v.add(ErrNoBody, "")
+	}
+}
+
+// verifyAttachments verify the total size in bytes of the attachments, max <= 0 means no limit
+func (v *validation) verifyAttachments(size, max int64) {
+	if max > 0 && size > max {
+		v.add(ErrAttachmentTooLarge, "max attachment size for %s is %dMB", v.service, max/1000000)
+	}
+}
+
+// err return a *ValidationError if any rule is violated
+func (v *validation) err() error {
+	if len(v.violations) <= 0 {
+		return nil
+	}
+	return &ValidationError{Service: v.service, Violations: v.violations}
+}
+
+// unsupportedError describes the features a driver is not able to handle
+type unsupportedError struct {
+	service  string
+	features []string
+}
+
+// Error implements the error interface
+func (e *unsupportedError) Error() string {
+	return fmt.Sprintf("gomailer: %s does not support %s", e.service, strings.Join(e.features, ", "))
+}
+
+// Unwrap makes the error comparable with ErrUnsupported using errors.Is
+func (e *unsupportedError) Unwrap() error {
+	return ErrUnsupported
+}
+
+// unsupportedTemplate return an *unsupportedError if the message uses a
+// hosted template, for drivers whose provider does not render them
+func unsupportedTemplate(service string, msg *Message) error {
+	if msg.Template == "" {
+		return nil
+	}
+	return &unsupportedError{service: service, features: []string{"hosted templates"}}
+}
+
+// LocalError describes a failure of a driver before the request reached the
+// provider, such as a payload which can not be encoded or a malformed base
+// url. Sending the same message again fails the same way
+type LocalError struct {
+	Service string // Service represents the driver which failed, empty for the shared form client
+	Err     error  // Err represents the underlying failure
+}
+
+// Error implements the error interface
+func (e *LocalError) Error() string {
+	if e.Service == "" {
+		return fmt.Sprintf("gomailer: could not build the request: %v", e.Err)
+	}
+	return fmt.Sprintf("gomailer: %s could not build the request: %v", e.Service, e.Err)
+}
+
+// Unwrap return the underlying failure
+func (e *LocalError) Unwrap() error {
+	return e.Err
+}
+
+// newLocalError wrap a failure of a driver building the request
+func newLocalError(service string, err error) error {
+	return &LocalError{Service: service, Err: err}
+}
+
+// ProviderError describes an error reported by the email service, either
+// through a non 2xx http status or through the response body
+type ProviderError struct {
+	Service    string // Service represents the driver which received the error, e.g. sendgrid
+	StatusCode int    // StatusCode represents the http status code, or the reply code for smtp
+	Code       string // Code represents the provider specific error code if any
+	Message    string // Message represents the parsed error message
+	Body       []byte // Body represents the raw response body
+}
+
+// Error implements the error interface
+func (e *ProviderError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = strings.TrimSpace(string(e.Body))
+	}
+	if e.Code != "" {
+		return fmt.Sprintf("gomailer: %s responded with status %d (%s): %s", e.Service, e.StatusCode, e.Code, msg)
+	}
+	return fmt.Sprintf("gomailer: %s responded with status %d: %s", e.Service, e.StatusCode, msg)
+}
+
+// Retryable reports whether the same request may succeed later, which is the
+// case for throttling (429) and server side failures (5xx). Other 4xx
+// responses mean the request itself is wrong. For smtp 4xx replies are the
+// transient ones
+func (e *ProviderError) Retryable() bool {
+	if e.Service == "smtp" {
+		return e.StatusCode >= 400 && e.StatusCode < 500
+	}
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// newProviderError return a *ProviderError, the message falls back to the raw body
+func newProviderError(service string, status int, body []byte, code, message string) *ProviderError {
+	return &ProviderError{
+		Service:    service,
+		StatusCode: status,
+		Code:       code,
+		Message:    strings.TrimSpace(message),
+		Body:       body,
+	}
+}
@@ -0,0 +1,58 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAttachmentTooLarge(t *testing.T) {
+	for d := MAILGUN; d <= FILE; d++ {
+		s, err := NewSender(d, Configs{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, ok := s.(attachmentLimiter)
+		if !ok {
+			continue
+		}
+		msg := &Message{
+			From:        Address{Email: "john@example.com"},
+			To:          []Address{{Email: "jane@example.com"}},
+			Text:        "body",
+			Attachments: []Attachment{{FileName: "big.bin", Content: make([]byte, l.maxAttachmentSize()+1)}},
+		}
+		_, err = s.Send(context.Background(), msg)
+		var verr *ValidationError
+		if !errors.As(err, &verr) || !errors.Is(err, ErrAttachmentTooLarge) {
+			t.Errorf("driver %d: got %v, want a validation error matching ErrAttachmentTooLarge", d, err)
+		}
+	}
+}
+
+func TestUnsupportedFeatures(t *testing.T) {
+	inline := []Attachment{{FileName: "logo.png", Content: []byte("png"), Inline: true}}
+	tests := []struct {
+		name    string
+		driver  driver
+		configs Configs
+		msg     Message
+	}{
+		{"elastic email inline", ELASTICEMAIL, Configs{APIKey: "key"}, Message{Attachments: inline}},
+		{"customerio inline", CUSTOMERIO, Configs{APIKey: "key"}, Message{Attachments: inline}},
+		{"customerio cc", CUSTOMERIO, Configs{APIKey: "key"}, Message{Cc: []Address{{Email: "cc@example.com"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := NewSender(tt.driver, tt.configs)
+			msg := tt.msg
+			msg.From = Address{Email: "john@example.com"}
+			msg.To = []Address{{Email: "jane@example.com"}}
+			msg.Subject = "subject"
+			msg.HTML = "<img src='cid:logo.png'>"
+			if _, err := s.Send(context.Background(), &msg); !errors.Is(err, ErrUnsupported) {
+				t.Errorf("got %v, want ErrUnsupported", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBreakerThreshold describes the consecutive failures after which a sender is skipped
+	defaultBreakerThreshold = 3
+	// defaultBreakerCooldown describes how long a failing sender is skipped
+	defaultBreakerCooldown = time.Minute
+)
+
+type (
+	// FailoverSender describes a sender which tries its senders in order and
+	// moves to the next one when a provider is down. A sender failing the
+	// threshold times in a row is skipped for the cooldown period. It is safe
+	// for concurrent use
+	FailoverSender struct {
+		mu        sync.Mutex
+		threshold int
+		cooldown  time.Duration
+		senders   []*breaker
+	}
+
+	// breaker describes the circuit breaker state of a sender
+	breaker struct {
+		sender    Sender
+		failures  int
+		openUntil time.Time
+	}
+
+	// FailoverError describes the failure of every sender of a failover, the
+	// last error is unwrapped so errors.As finds the *ProviderError
+	FailoverError struct {
+		Errors []error
+	}
+)
+
+// Failover return a sender which tries primary first and then every secondary
+// sender in order
+func Failover(primary Sender, secondary ...Sender) *FailoverSender {
+	f := &FailoverSender{
+		threshold: defaultBreakerThreshold,
+		cooldown:  defaultBreakerCooldown,
+	}
+	for _, s := range append([]Sender{primary}, secondary...) {
+		f.senders = append(f.senders, &breaker{sender: s})
+	}
+	return f
+}
+
+// Breaker sets the consecutive failures after which a sender is skipped and
+// for how long, a threshold <= 0 disables the circuit breaker
+func (f *FailoverSender) Breaker(threshold int, cooldown time.Duration) *FailoverSender {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.threshold = threshold
+	f.cooldown = cooldown
+	return f
+}
+
+// Send process an email sending with the first available sender
+func (f *FailoverSender) Send(ctx context.Context, m *Message) (*SendResult, error) {
+	errs := []error{}
+	for _, b := range f.senders {
+		if !f.available(b) {
+			continue
+		}
+		result, err := b.sender.Send(ctx, m)
+		if err == nil {
+			f.record(b, true)
+			return result, nil
+		}
+		if !shouldFailover(ctx, err) {
+			// a rejection of the message itself says nothing about the provider
+			return result, err
+		}
+		if providerFailure(err) {
+			f.record(b, false)
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) <= 0 {
+		return nil, ErrCircuitOpen
+	}
+	return nil, &FailoverError{Errors: errs}
+}
+
+// available reports whether the circuit of a sender is closed or its cooldown is over
+func (f *FailoverSender) available(b *breaker) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.threshold <= 0 || b.failures < f.threshold || !time.Now().Before(b.openUntil)
+}
+
+// record update the circuit of a sender after an attempt
+func (f *FailoverSender) record(b *breaker, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if ok {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if f.threshold > 0 && b.failures >= f.threshold {
+		b.openUntil = time.Now().Add(f.cooldown)
+	}
+}
+
+// shouldFailover reports whether the next sender may succeed where err
+// happened: provider outages, throttling, network failures and features the
+// provider does not support. Invalid emails would fail everywhere
+func shouldFailover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return false
+	}
+	var perr *ProviderError
+	if errors.As(err, &perr) {
+		return perr.Retryable()
+	}
+	return true
+}
+
+// providerFailure reports whether err counts toward the circuit breaker. An
+// unsupported feature, the client side rate limiter or a local failure such as
+// a bad base url moves the message to the next sender but says nothing about
+// the health of the provider
+func providerFailure(err error) bool {
+	var lerr *LocalError
+	return !errors.Is(err, ErrUnsupported) && !errors.Is(err, ErrRateLimited) && !errors.As(err, &lerr)
+}
+
+// Error implements the error interface
+func (e *FailoverError) Error() string {
+	msgs := []string{}
+	for _, err := range e.Errors {
+		msgs = append(msgs, strings.TrimPrefix(err.Error(), "gomailer: "))
+	}
+	return fmt.Sprintf("gomailer: every sender failed: %s", strings.Join(msgs, "; "))
+}
+
+// Unwrap return the error of the last sender
+func (e *FailoverError) Unwrap() error {
+	return e.Errors[len(e.Errors)-1]
+}
@@ -0,0 +1,72 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubSender describes a sender returning err, it counts its calls
+type stubSender struct {
+	mu    sync.Mutex
+	err   error
+	calls int
+}
+
+// Send implements Sender
+func (s *stubSender) Send(ctx context.Context, m *Message) (*SendResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &SendResult{Service: "stub", MessageIDs: []string{"id"}}, nil
+}
+
+// Calls return the number of sends
+func (s *stubSender) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func TestFailoverBreaker(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		open bool // open represents whether the primary is skipped after the threshold
+	}{
+		{"outage", newProviderError("sendgrid", 503, nil, "", "down"), true},
+		{"network", errors.New("connection refused"), true},
+		{"unsupported", unsupportedTemplate("jangomail", &Message{Template: "welcome"}), false},
+		{"client rate limit", &RateLimitError{Service: "sendgrid", Wait: time.Second}, false},
+		{"local", newLocalError("sendgrid", errors.New("bad url")), false},
+	}
+	for _, tc := range cases {
+		primary, secondary := &stubSender{err: tc.err}, &stubSender{}
+		f := Failover(primary, secondary).Breaker(3, time.Minute)
+		for i := 0; i < 5; i++ {
+			if _, err := f.Send(context.Background(), &Message{}); err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+		}
+		want := 5
+		if tc.open {
+			want = 3
+		}
+		if primary.Calls() != want || secondary.Calls() != 5 {
+			t.Errorf("%s: primary %d calls, want %d, secondary %d calls, want 5", tc.name, primary.Calls(), want, secondary.Calls())
+		}
+	}
+}
+
+func TestFailoverInvalidEmail(t *testing.T) {
+	primary, secondary := &stubSender{err: &ValidationError{Service: "stub", Violations: []error{ErrNoFrom}}}, &stubSender{}
+	_, err := Failover(primary, secondary).Send(context.Background(), &Message{})
+	if !errors.Is(err, ErrNoFrom) || secondary.Calls() != 0 {
+		t.Errorf("got %v and %d secondary calls, want the validation error and none", err, secondary.Calls())
+	}
+}
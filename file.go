@@ -0,0 +1,71 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fileTimeFormat describes the time prefix of the file names, sorting by name sorts by time
+const fileTimeFormat = "20060102T150405.000000000"
+
+// file describes a file type, it writes every message as an .eml file
+// instead of sending it, meant for development and staging
+type file struct {
+	c       client
+	configs Configs
+}
+
+// Send process an email sending and return the provider response
+func (f *file) Send(ctx context.Context, msg *Message) (*SendResult, error) {
+	// the file holds the final email, nobody renders a hosted template
+	if err := unsupportedTemplate("file", msg); err != nil {
+		return nil, err
+	}
+
+	// verify params for sending email
+	if err := f.verifyParams(msg); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// bcc receipents are kept in the headers so the file shows every receipent
+	mm := mimeMessage{Message: msg, bcc: true}
+	mm.id = mm.messageID()
+	body, err := mm.bytes()
+	if err != nil {
+		return nil, newLocalError("file", err)
+	}
+
+	if err := os.MkdirAll(f.configs.Directory, 0755); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format(fileTimeFormat), uuid.New().String())
+	path := filepath.Join(f.configs.Directory, name)
+	if err := ioutil.WriteFile(path, body, 0644); err != nil {
+		return nil, err
+	}
+
+	r := newSendResult("file", 0, nil, msg.To, msg.Cc, msg.Bcc)
+	r.MessageIDs = []string{mm.id}
+	return r, nil
+}
+
+// verifyParams verify the required params
+func (f file) verifyParams(msg *Message) error {
+	v := validation{service: "file"}
+	if f.configs.Directory == "" {
+		v.add(ErrInvalidConfig, "for file you must provide Directory in config")
+	}
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), 0)
+	v.verifyBody(msg)
+	return v.err()
+}
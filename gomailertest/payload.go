@@ -0,0 +1,120 @@
+package gomailertest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// object describes a decoded json object, unlike decoding into a struct the
+// keys are matched case sensitively as the providers do
+type object map[string]interface{}
+
+// asObject return v as an object or an error naming where it was expected
+func asObject(v interface{}, where string) (object, error) {
+	o, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object", where)
+	}
+	return o, nil
+}
+
+// asList return v as a non empty list or an error naming where it was expected
+func asList(v interface{}, where string) ([]interface{}, error) {
+	l, ok := v.([]interface{})
+	if !ok || len(l) <= 0 {
+		return nil, fmt.Errorf("%s must be a non empty array", where)
+	}
+	return l, nil
+}
+
+// has reports whether key is set to a non empty value
+func (o object) has(key string) bool {
+	switch v := o[key].(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// require return an error naming the first missing key
+func (o object) require(where string, keys ...string) error {
+	for _, k := range keys {
+		if !o.has(k) {
+			return fmt.Errorf("%s: missing required field %q", where, k)
+		}
+	}
+	return nil
+}
+
+// requireOne return an error if none of the keys is set
+func (o object) requireOne(where string, keys ...string) error {
+	for _, k := range keys {
+		if o.has(k) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: one of %s is required", where, strings.Join(keys, ", "))
+}
+
+// strings return an error if a present key is not a string
+func (o object) strings(where string, keys ...string) error {
+	for _, k := range keys {
+		if _, ok := o[k]; !ok {
+			continue
+		}
+		if _, ok := o[k].(string); !ok {
+			return fmt.Errorf("%s: %q must be a string", where, k)
+		}
+	}
+	return nil
+}
+
+// address verify an address object with the email under key
+func address(v interface{}, where, key string) error {
+	o, err := asObject(v, where)
+	if err != nil {
+		return err
+	}
+	return o.require(where, key)
+}
+
+// addresses verify a list of address objects, the list is optional unless required
+func (o object) addresses(where, field, key string, required bool) error {
+	if _, ok := o[field]; !ok && !required {
+		return nil
+	}
+	list, err := asList(o[field], where+"."+field)
+	if err != nil {
+		return err
+	}
+	for i, a := range list {
+		if err := address(a, fmt.Sprintf("%s.%s[%d]", where, field, i), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formRequire return an error naming the first missing form field
+func formRequire(form map[string][]string, keys ...string) error {
+	for _, k := range keys {
+		if len(form[k]) <= 0 || form[k][0] == "" {
+			return fmt.Errorf("missing required field %q", k)
+		}
+	}
+	return nil
+}
+
+// formHas reports whether any of the form fields is set
+func formHas(form map[string][]string, keys ...string) bool {
+	for _, k := range keys {
+		if len(form[k]) > 0 && form[k][0] != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,473 @@
+package gomailertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type (
+	// mailgun describes the mailgun messages api
+	mailgun struct {
+		apiKey string
+		domain string
+	}
+
+	// sendgrid describes the sendgrid v3 mail send api
+	sendgrid struct {
+		apiKey string
+	}
+
+	// postmark describes the postmark email api
+	postmark struct {
+		token string
+	}
+
+	// mailjet describes the mailjet v3.1 send api
+	mailjet struct {
+		publicKey  string
+		privateKey string
+	}
+
+	// customerio describes the customer.io transactional api
+	customerio struct {
+		apiKey string
+	}
+)
+
+// NewMailgun start a fake mailgun server expecting the api key and domain
+func NewMailgun(apiKey, domain string) *Server {
+	return newServer(mailgun{apiKey: apiKey, domain: domain})
+}
+
+// NewSendGrid start a fake sendgrid server expecting the api key
+func NewSendGrid(apiKey string) *Server {
+	return newServer(sendgrid{apiKey: apiKey})
+}
+
+// NewPostmark start a fake postmark server expecting the server or account token
+func NewPostmark(token string) *Server {
+	return newServer(postmark{token: token})
+}
+
+// NewMailjet start a fake mailjet server expecting the public and private key
+func NewMailjet(publicKey, privateKey string) *Server {
+	return newServer(mailjet{publicKey: publicKey, privateKey: privateKey})
+}
+
+// NewCustomerIO start a fake customer.io server expecting the api key
+func NewCustomerIO(apiKey string) *Server {
+	return newServer(customerio{apiKey: apiKey})
+}
+
+func (m mailgun) authorized(r *http.Request) bool {
+	user, pass, ok := r.BasicAuth()
+	return ok && user == "api" && pass == m.apiKey
+}
+
+func (m mailgun) paths() []string {
+	return []string{fmt.Sprintf("/%s/messages", m.domain)}
+}
+
+// decode parse the multipart form, fields and files are recorded separately
+func (m mailgun) decode(r *http.Request, req *Request) error {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		return err
+	}
+	req.Form = r.MultipartForm.Value
+	for field, headers := range r.MultipartForm.File {
+		for _, h := range headers {
+			f, err := h.Open()
+			if err != nil {
+				return err
+			}
+			b, err := ioutil.ReadAll(f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			req.Files = append(req.Files, File{Field: field, FileName: h.Filename, Content: b})
+		}
+	}
+	// the parsed form is a map, sort the files so recordings are stable
+	req.Files = sortFiles(req.Files)
+	return m.validate(req.Form)
+}
+
+// validate verify the fields required by the messages api, a template brings its own subject and body
+func (mailgun) validate(form map[string][]string) error {
+	if err := formRequire(form, "from", "to"); err != nil {
+		return err
+	}
+	if !formHas(form, "template") {
+		if err := formRequire(form, "subject"); err != nil {
+			return err
+		}
+	}
+	if !formHas(form, "text", "html", "template") {
+		return fmt.Errorf("one of text, html, template is required")
+	}
+	return nil
+}
+
+func (m mailgun) success(req Request, id string) Response {
+	return jsonResponse(http.StatusOK, map[string]string{
+		"id":      fmt.Sprintf("<%s@%s>", id, m.domain),
+		"message": "Queued. Thank you.",
+	})
+}
+
+func (mailgun) failure(status int, message string) Response {
+	return jsonResponse(status, map[string]string{"message": message})
+}
+
+func (s sendgrid) authorized(r *http.Request) bool {
+	return r.Header.Get("Authorization") == "Bearer "+s.apiKey
+}
+
+func (sendgrid) paths() []string {
+	return []string{"/mail/send"}
+}
+
+func (s sendgrid) decode(r *http.Request, req *Request) error {
+	if err := decodeJSON(req); err != nil {
+		return err
+	}
+	return s.validate(req.JSON)
+}
+
+// validate verify the fields required by the mail send api, a dynamic
+// template brings its own subject and content
+func (sendgrid) validate(v interface{}) error {
+	body, err := asObject(v, "body")
+	if err != nil {
+		return err
+	}
+	if err := body.require("body", "personalizations", "from"); err != nil {
+		return err
+	}
+	if err := address(body["from"], "from", "email"); err != nil {
+		return err
+	}
+	if _, ok := body["reply_to"]; ok {
+		if err := address(body["reply_to"], "reply_to", "email"); err != nil {
+			return err
+		}
+	}
+	template := body.has("template_id")
+	personalizations, err := asList(body["personalizations"], "personalizations")
+	if err != nil {
+		return err
+	}
+	for i, p := range personalizations {
+		where := fmt.Sprintf("personalizations[%d]", i)
+		po, err := asObject(p, where)
+		if err != nil {
+			return err
+		}
+		if err := po.addresses(where, "to", "email", true); err != nil {
+			return err
+		}
+		for _, field := range []string{"cc", "bcc"} {
+			if err := po.addresses(where, field, "email", false); err != nil {
+				return err
+			}
+		}
+		if !template && !po.has("subject") && !body.has("subject") {
+			return fmt.Errorf("%s: missing required field \"subject\"", where)
+		}
+	}
+	if template {
+		return nil
+	}
+	content, err := asList(body["content"], "content")
+	if err != nil {
+		return err
+	}
+	for i, c := range content {
+		co, err := asObject(c, fmt.Sprintf("content[%d]", i))
+		if err != nil {
+			return err
+		}
+		if err := co.require(fmt.Sprintf("content[%d]", i), "type", "value"); err != nil {
+			return err
+		}
+		if i == 0 && len(content) > 1 && co["type"] != "text/plain" {
+			return fmt.Errorf("content[0]: text/plain must come first")
+		}
+	}
+	return nil
+}
+
+// success return an empty 202, sendgrid sends the message id in a header
+func (sendgrid) success(req Request, id string) Response {
+	return Response{
+		Status: http.StatusAccepted,
+		Header: http.Header{"X-Message-Id": []string{id}},
+	}
+}
+
+func (sendgrid) failure(status int, message string) Response {
+	return jsonResponse(status, map[string]interface{}{
+		"errors": []map[string]string{{"message": message}},
+	})
+}
+
+func (p postmark) authorized(r *http.Request) bool {
+	return r.Header.Get("X-Postmark-Server-Token") == p.token || r.Header.Get("X-Postmark-Account-Token") == p.token
+}
+
+// paths return the single and the batch endpoints, with and without template
+func (postmark) paths() []string {
+	return []string{"/email", "/email/withTemplate", "/email/batch", "/email/batchWithTemplates"}
+}
+
+func (p postmark) decode(r *http.Request, req *Request) error {
+	if err := decodeJSON(req); err != nil {
+		return err
+	}
+	template := strings.HasSuffix(req.Path, "/withTemplate") || strings.HasSuffix(req.Path, "/batchWithTemplates")
+	switch req.Path {
+	case "/email/batch":
+		list, err := asList(req.JSON, "body")
+		if err != nil {
+			return err
+		}
+		return p.validateAll(list, template)
+	case "/email/batchWithTemplates":
+		body, err := asObject(req.JSON, "body")
+		if err != nil {
+			return err
+		}
+		list, err := asList(body["Messages"], "Messages")
+		if err != nil {
+			return err
+		}
+		return p.validateAll(list, template)
+	}
+	return p.validate(req.JSON, "body", template)
+}
+
+// validateAll verify every email of a batch
+func (p postmark) validateAll(list []interface{}, template bool) error {
+	for i, m := range list {
+		if err := p.validate(m, fmt.Sprintf("[%d]", i), template); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate verify the fields required by the email api, addresses are comma separated strings
+func (postmark) validate(v interface{}, where string, template bool) error {
+	msg, err := asObject(v, where)
+	if err != nil {
+		return err
+	}
+	if err := msg.require(where, "From", "To"); err != nil {
+		return err
+	}
+	if err := msg.strings(where, "From", "To", "Cc", "Bcc", "ReplyTo"); err != nil {
+		return err
+	}
+	if template {
+		if err := msg.requireOne(where, "TemplateId", "TemplateAlias"); err != nil {
+			return err
+		}
+		if _, err := asObject(msg["TemplateModel"], where+".TemplateModel"); err != nil {
+			return err
+		}
+		return nil
+	}
+	return msg.requireOne(where, "HtmlBody", "TextBody")
+}
+
+// success return the status of the email, or of every email of a batch
+func (postmark) success(req Request, id string) Response {
+	status := func(id string) map[string]interface{} {
+		return map[string]interface{}{
+			"MessageID":   id,
+			"SubmittedAt": time.Now().UTC().Format(time.RFC3339),
+			"ErrorCode":   0,
+			"Message":     "OK",
+		}
+	}
+	var messages []interface{}
+	switch body := req.JSON.(type) {
+	case []interface{}:
+		messages = body
+	case map[string]interface{}:
+		if req.Path != "/email/batchWithTemplates" {
+			return jsonResponse(http.StatusOK, status(id))
+		}
+		messages, _ = body["Messages"].([]interface{})
+	}
+	batch := []map[string]interface{}{}
+	for i := range messages {
+		batch = append(batch, status(fmt.Sprintf("%s-%d", id, i+1)))
+	}
+	return jsonResponse(http.StatusOK, batch)
+}
+
+// failure return a postmark error, the api error code 10 is a bad token
+func (postmark) failure(status int, message string) Response {
+	code := 300
+	if status == http.StatusUnauthorized {
+		code = 10
+	}
+	return jsonResponse(status, map[string]interface{}{"ErrorCode": code, "Message": message})
+}
+
+func (m mailjet) authorized(r *http.Request) bool {
+	user, pass, ok := r.BasicAuth()
+	return ok && user == m.publicKey && pass == m.privateKey
+}
+
+func (mailjet) paths() []string {
+	return []string{"/send"}
+}
+
+func (m mailjet) decode(r *http.Request, req *Request) error {
+	if err := decodeJSON(req); err != nil {
+		return err
+	}
+	return m.validate(req.JSON)
+}
+
+// validate verify the fields required by the send api, the address keys are capitalized
+func (mailjet) validate(v interface{}) error {
+	body, err := asObject(v, "body")
+	if err != nil {
+		return err
+	}
+	messages, err := asList(body["Messages"], "Messages")
+	if err != nil {
+		return err
+	}
+	for i, m := range messages {
+		where := fmt.Sprintf("Messages[%d]", i)
+		msg, err := asObject(m, where)
+		if err != nil {
+			return err
+		}
+		if err := msg.require(where, "From"); err != nil {
+			return err
+		}
+		if err := address(msg["From"], where+".From", "Email"); err != nil {
+			return err
+		}
+		if err := msg.addresses(where, "To", "Email", true); err != nil {
+			return err
+		}
+		for _, field := range []string{"Cc", "Bcc"} {
+			if err := msg.addresses(where, field, "Email", false); err != nil {
+				return err
+			}
+		}
+		if _, ok := msg["ReplyTo"]; ok {
+			if err := address(msg["ReplyTo"], where+".ReplyTo", "Email"); err != nil {
+				return err
+			}
+		}
+		if err := msg.requireOne(where, "TextPart", "HTMLPart", "TemplateID"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// success return the status of every receipent, each one gets its own message uuid
+func (mailjet) success(req Request, id string) Response {
+	type receipent struct {
+		Email       string `json:"Email"`
+		MessageUUID string `json:"MessageUUID"`
+	}
+	payload := struct {
+		Messages []struct {
+			To  []receipent `json:"To"`
+			Cc  []receipent `json:"Cc"`
+			Bcc []receipent `json:"Bcc"`
+		} `json:"Messages"`
+	}{}
+	_ = json.Unmarshal(req.Body, &payload)
+	n := 0
+	uuids := func(list []receipent) []receipent {
+		out := []receipent{}
+		for _, a := range list {
+			n++
+			out = append(out, receipent{Email: a.Email, MessageUUID: fmt.Sprintf("%s-%d", id, n)})
+		}
+		return out
+	}
+	messages := []map[string]interface{}{}
+	for _, m := range payload.Messages {
+		messages = append(messages, map[string]interface{}{
+			"Status": "success",
+			"To":     uuids(m.To),
+			"Cc":     uuids(m.Cc),
+			"Bcc":    uuids(m.Bcc),
+		})
+	}
+	return jsonResponse(http.StatusOK, map[string]interface{}{"Messages": messages})
+}
+
+func (mailjet) failure(status int, message string) Response {
+	return jsonResponse(status, map[string]interface{}{
+		"ErrorCode":    fmt.Sprintf("mj-%04d", status),
+		"ErrorMessage": message,
+		"StatusCode":   status,
+	})
+}
+
+func (c customerio) authorized(r *http.Request) bool {
+	return r.Header.Get("Authorization") == "Bearer "+c.apiKey
+}
+
+func (customerio) paths() []string {
+	return []string{"/v1/send/email"}
+}
+
+func (c customerio) decode(r *http.Request, req *Request) error {
+	if err := decodeJSON(req); err != nil {
+		return err
+	}
+	return c.validate(req.JSON)
+}
+
+// validate verify the fields required by the send email api, a transactional
+// message brings its own sender, subject and body
+func (customerio) validate(v interface{}) error {
+	body, err := asObject(v, "body")
+	if err != nil {
+		return err
+	}
+	if err := body.require("body", "identifiers", "to"); err != nil {
+		return err
+	}
+	if _, err := asObject(body["identifiers"], "identifiers"); err != nil {
+		return err
+	}
+	if err := body.strings("body", "to", "from", "bcc", "reply_to"); err != nil {
+		return err
+	}
+	if body.has("transactional_message_id") {
+		return nil
+	}
+	return body.require("body", "from", "subject", "body")
+}
+
+func (customerio) success(req Request, id string) Response {
+	return jsonResponse(http.StatusOK, map[string]interface{}{
+		"delivery_id": id,
+		"queued_at":   time.Now().Unix(),
+	})
+}
+
+func (customerio) failure(status int, message string) Response {
+	return jsonResponse(status, map[string]interface{}{
+		"meta": map[string]string{"error": message},
+	})
+}
@@ -0,0 +1,447 @@
+// Package gomailertest provides fake email service servers to test an
+// integration against the wire format of each gomailer driver. A server plugs
+// in through Configs.BaseURL, rejects payloads missing the fields the
+// provider requires, records every request and can be scripted to return
+// errors, throttling or slow responses. A recording can be saved and replayed
+// to catch changes of the payloads sent
+package gomailertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Request describes a request received by a fake server
+	Request struct {
+		Method string              `json:"method"`
+		Path   string              `json:"path"`
+		Header http.Header         `json:"header,omitempty"`
+		Body   []byte              `json:"body,omitempty"`  // Body represents the raw request body
+		JSON   interface{}         `json:"json,omitempty"`  // JSON represents the decoded body of json apis, a map or a slice for batches
+		Form   map[string][]string `json:"form,omitempty"`  // Form represents the fields of multipart apis
+		Files  []File              `json:"files,omitempty"` // Files represents the files of multipart apis
+	}
+
+	// File describes a file uploaded in a multipart request
+	File struct {
+		Field    string `json:"field"`
+		FileName string `json:"file_name"`
+		Content  []byte `json:"content"`
+	}
+
+	// Response describes a scripted response, a zero Status sends the
+	// provider success response after Delay
+	Response struct {
+		Status int           `json:"status"`
+		Header http.Header   `json:"header,omitempty"`
+		Body   string        `json:"body,omitempty"`
+		Delay  time.Duration `json:"delay,omitempty"`
+	}
+
+	// Interaction describes a received request and the response sent
+	Interaction struct {
+		Request  Request  `json:"request"`
+		Response Response `json:"response"`
+	}
+
+	// Server describes a fake email service
+	Server struct {
+		*httptest.Server
+		mu           sync.Mutex
+		interactions []Interaction
+		script       []Response
+		sent         int
+		provider     provider
+		recording    []Interaction
+		replayed     int
+		ignore       []string
+	}
+
+	// provider describes the wire format of an email service
+	provider interface {
+		// authorized reports whether the request carries the expected credentials
+		authorized(r *http.Request) bool
+		// paths return the paths the driver sends to
+		paths() []string
+		// decode decode the payload into the recorded request
+		decode(r *http.Request, req *Request) error
+		// success return the success response of a request with the message id
+		success(req Request, id string) Response
+		// failure return an error response in the provider format
+		failure(status int, message string) Response
+	}
+)
+
+// newServer start a fake server for the provider
+func newServer(p provider) *Server {
+	s := &Server{provider: p}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// BaseURL return the url to set as Configs.BaseURL
+func (s *Server) BaseURL() string {
+	return s.URL
+}
+
+// handle record a request and write the next scripted or the success response
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req := Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Body:   body,
+	}
+
+	var resp Response
+	switch {
+	case r.Method != http.MethodPost || !s.routed(r.URL.Path):
+		resp = s.provider.failure(http.StatusNotFound, "not found")
+	case !s.provider.authorized(r):
+		resp = s.provider.failure(http.StatusUnauthorized, "unauthorized")
+	default:
+		if err := s.provider.decode(r, &req); err != nil {
+			resp = s.provider.failure(http.StatusBadRequest, err.Error())
+		} else if s.replaying() {
+			resp = s.replay(req)
+		} else {
+			resp = s.next(req)
+		}
+	}
+
+	s.mu.Lock()
+	s.interactions = append(s.interactions, Interaction{Request: req, Response: resp})
+	s.mu.Unlock()
+
+	if resp.Delay > 0 {
+		select {
+		case <-time.After(resp.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write([]byte(resp.Body))
+}
+
+// routed reports whether the provider serves the path
+func (s *Server) routed(path string) bool {
+	for _, p := range s.provider.paths() {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// next pop the next scripted response, falling back to the success response
+func (s *Server) next(req Request) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := Response{}
+	if len(s.script) > 0 {
+		resp = s.script[0]
+		s.script = s.script[1:]
+	}
+	if resp.Status != 0 {
+		return resp
+	}
+	s.sent++
+	success := s.provider.success(req, fmt.Sprintf("gomailertest-%d", s.sent))
+	success.Delay = resp.Delay
+	return success
+}
+
+// Enqueue script the next responses, they are used in order before falling
+// back to the success response
+func (s *Server) Enqueue(responses ...Response) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append(s.script, responses...)
+	return s
+}
+
+// Fail script the next response as an error in the provider format
+func (s *Server) Fail(status int, message string) *Server {
+	return s.Enqueue(s.provider.failure(status, message))
+}
+
+// Throttle script the next response as a 429 with a Retry-After header
+func (s *Server) Throttle(retryAfter time.Duration) *Server {
+	resp := s.provider.failure(http.StatusTooManyRequests, "too many requests")
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	resp.Header.Set("Retry-After", strconv.Itoa(int(retryAfter/time.Second)))
+	return s.Enqueue(resp)
+}
+
+// Slow script the next response as a success sent after d
+func (s *Server) Slow(d time.Duration) *Server {
+	return s.Enqueue(Response{Delay: d})
+}
+
+// Requests return the received requests in order
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := []Request{}
+	for _, i := range s.interactions {
+		requests = append(requests, i.Request)
+	}
+	return requests
+}
+
+// Last return the last received request, nil if there is none
+func (s *Server) Last() *Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.interactions) <= 0 {
+		return nil
+	}
+	req := s.interactions[len(s.interactions)-1].Request
+	return &req
+}
+
+// Interactions return the received requests with the responses sent, in order
+func (s *Server) Interactions() []Interaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Interaction(nil), s.interactions...)
+}
+
+// Reset forget the received requests and the scripted responses, a replay
+// starts over from the first recorded interaction
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interactions = nil
+	s.script = nil
+	s.replayed = 0
+}
+
+// Save write the interactions as json, the credential headers are left out
+func (s *Server) Save(w io.Writer) error {
+	interactions := s.Interactions()
+	for i := range interactions {
+		h := interactions[i].Request.Header.Clone()
+		for k := range h {
+			if k == "Authorization" || strings.HasPrefix(k, "X-Postmark-") {
+				h.Del(k)
+			}
+		}
+		interactions[i].Request.Header = h
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(interactions)
+}
+
+// Load read interactions written by Save
+func Load(r io.Reader) ([]Interaction, error) {
+	interactions := []Interaction{}
+	if err := json.NewDecoder(r).Decode(&interactions); err != nil {
+		return nil, err
+	}
+	return interactions, nil
+}
+
+// Replay answer the next requests with the recorded responses, in order. A
+// request whose method, path or decoded payload differs from the recorded
+// one, or which comes after the recording ran out, gets a 400 error naming
+// the difference. The ignored fields are dotted paths into the json payload,
+// a * matches any key or index, or the names of multipart fields. Scripted
+// responses are not used while replaying
+func (s *Server) Replay(recording []Interaction, ignore ...string) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recording = recording
+	s.replayed = 0
+	s.ignore = ignore
+	return s
+}
+
+// replaying reports whether the server replays a recording
+func (s *Server) replaying() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.recording != nil
+}
+
+// replay answer a request with the next recorded response
+func (s *Server) replay(req Request) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.replayed >= len(s.recording) {
+		return s.provider.failure(http.StatusBadRequest, fmt.Sprintf("gomailertest: request %d is not in the recording", s.replayed+1))
+	}
+	want := s.recording[s.replayed]
+	s.replayed++
+	if diff := s.mismatch(want.Request, req); diff != "" {
+		return s.provider.failure(http.StatusBadRequest, fmt.Sprintf("gomailertest: request %d does not match the recording: %s", s.replayed, diff))
+	}
+	return want.Response
+}
+
+// mismatch return the first difference between the recorded and the received request
+func (s *Server) mismatch(want, got Request) string {
+	if want.Method != got.Method || want.Path != got.Path {
+		return fmt.Sprintf("got %s %s, want %s %s", got.Method, got.Path, want.Method, want.Path)
+	}
+	if diff := jsonDiff("", want.JSON, got.JSON, s.ignore); diff != "" {
+		return diff
+	}
+	keys := map[string]bool{}
+	for k := range want.Form {
+		keys[k] = true
+	}
+	for k := range got.Form {
+		keys[k] = true
+	}
+	fields := []string{}
+	for k := range keys {
+		if !ignored(k, s.ignore) {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	for _, k := range fields {
+		if !reflect.DeepEqual(want.Form[k], got.Form[k]) {
+			return fmt.Sprintf("%s: got %q, want %q", k, got.Form[k], want.Form[k])
+		}
+	}
+	// recordings saved before the files were sorted may hold them in any order
+	if !reflect.DeepEqual(sortFiles(want.Files), sortFiles(got.Files)) {
+		return "the files differ"
+	}
+	return ""
+}
+
+// sortFiles return a copy of the files ordered by field then file name
+func sortFiles(files []File) []File {
+	sorted := append([]File(nil), files...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Field != sorted[j].Field {
+			return sorted[i].Field < sorted[j].Field
+		}
+		return sorted[i].FileName < sorted[j].FileName
+	})
+	return sorted
+}
+
+// jsonDiff return the first difference between two decoded json values
+func jsonDiff(path string, want, got interface{}, ignore []string) string {
+	if ignored(path, ignore) {
+		return ""
+	}
+	name := path
+	if name == "" {
+		name = "body"
+	}
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			return fmt.Sprintf("%s: got %v, want an object", name, got)
+		}
+		keys := []string{}
+		for k := range w {
+			keys = append(keys, k)
+		}
+		for k := range g {
+			if _, ok := w[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if diff := jsonDiff(join(path, k), w[k], g[k], ignore); diff != "" {
+				return diff
+			}
+		}
+		return ""
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok || len(g) != len(w) {
+			return fmt.Sprintf("%s: got %v, want %v", name, got, want)
+		}
+		for i := range w {
+			if diff := jsonDiff(join(path, strconv.Itoa(i)), w[i], g[i], ignore); diff != "" {
+				return diff
+			}
+		}
+		return ""
+	}
+	if !reflect.DeepEqual(want, got) {
+		return fmt.Sprintf("%s: got %v, want %v", name, got, want)
+	}
+	return ""
+}
+
+// join append a key to a dotted path
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// ignored reports whether a dotted path matches one of the ignored paths
+func ignored(path string, ignore []string) bool {
+	if path == "" {
+		return false
+	}
+	parts := strings.Split(path, ".")
+	for _, i := range ignore {
+		pattern := strings.Split(i, ".")
+		if len(pattern) != len(parts) {
+			continue
+		}
+		match := true
+		for n := range pattern {
+			if pattern[n] != "*" && pattern[n] != parts[n] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonResponse return a json response with the status
+func jsonResponse(status int, v interface{}) Response {
+	b, _ := json.Marshal(v)
+	return Response{
+		Status: status,
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   string(b),
+	}
+}
+
+// decodeJSON decode a json request body
+func decodeJSON(req *Request) error {
+	if ct := req.Header.Get("Content-Type"); ct != "application/json" {
+		return fmt.Errorf("unexpected content type %q", ct)
+	}
+	return json.Unmarshal(req.Body, &req.JSON)
+}
@@ -0,0 +1,176 @@
+package gomailertest
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// post send a json body with the credentials of the fake
+func post(t *testing.T, srv *Server, path, body string, auth func(*http.Request)) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	auth(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func bearer(r *http.Request) { r.Header.Set("Authorization", "Bearer key") }
+
+func basic(r *http.Request) { r.SetBasicAuth("pub", "priv") }
+
+func serverToken(r *http.Request) { r.Header.Set("X-Postmark-Server-Token", "token") }
+
+func TestRequiredFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		srv    *Server
+		path   string
+		auth   func(*http.Request)
+		body   string
+		status int
+	}{
+		{"sendgrid", NewSendGrid("key"), "/mail/send", bearer,
+			`{"from":{"email":"f@x.com"},"personalizations":[{"to":[{"email":"t@x.com"}],"subject":"s"}],"content":[{"type":"text/plain","value":"v"}]}`, http.StatusAccepted},
+		{"sendgrid without to", NewSendGrid("key"), "/mail/send", bearer,
+			`{"from":{"email":"f@x.com"},"personalizations":[{"subject":"s"}],"content":[{"type":"text/plain","value":"v"}]}`, http.StatusBadRequest},
+		{"sendgrid null cc", NewSendGrid("key"), "/mail/send", bearer,
+			`{"from":{"email":"f@x.com"},"personalizations":[{"to":[{"email":"t@x.com"}],"cc":null,"subject":"s"}],"content":[{"type":"text/plain","value":"v"}]}`, http.StatusBadRequest},
+		{"sendgrid html first", NewSendGrid("key"), "/mail/send", bearer,
+			`{"from":{"email":"f@x.com"},"personalizations":[{"to":[{"email":"t@x.com"}],"subject":"s"}],"content":[{"type":"text/html","value":"v"},{"type":"text/plain","value":"v"}]}`, http.StatusBadRequest},
+		{"mailjet", NewMailjet("pub", "priv"), "/send", basic,
+			`{"Messages":[{"From":{"Email":"f@x.com"},"To":[{"Email":"t@x.com"}],"TextPart":"v"}]}`, http.StatusOK},
+		{"mailjet lowercase keys", NewMailjet("pub", "priv"), "/send", basic,
+			`{"Messages":[{"From":{"email":"f@x.com"},"To":[{"email":"t@x.com"}],"TextPart":"v"}]}`, http.StatusBadRequest},
+		{"mailjet without body", NewMailjet("pub", "priv"), "/send", basic,
+			`{"Messages":[{"From":{"Email":"f@x.com"},"To":[{"Email":"t@x.com"}]}]}`, http.StatusBadRequest},
+		{"postmark", NewPostmark("token"), "/email", serverToken,
+			`{"From":"f@x.com","To":"t@x.com","TextBody":"v"}`, http.StatusOK},
+		{"postmark reply to object", NewPostmark("token"), "/email", serverToken,
+			`{"From":"f@x.com","To":"t@x.com","ReplyTo":{"Email":"r@x.com"},"TextBody":"v"}`, http.StatusBadRequest},
+		{"postmark template without model", NewPostmark("token"), "/email/withTemplate", serverToken,
+			`{"From":"f@x.com","To":"t@x.com","TemplateAlias":"welcome"}`, http.StatusBadRequest},
+		{"postmark batch without to", NewPostmark("token"), "/email/batch", serverToken,
+			`[{"From":"f@x.com","To":"t@x.com","TextBody":"v"},{"From":"f@x.com","TextBody":"v"}]`, http.StatusBadRequest},
+		{"customerio", NewCustomerIO("key"), "/v1/send/email", bearer,
+			`{"identifiers":{"id":"1"},"to":"t@x.com","from":"f@x.com","subject":"s","body":"v"}`, http.StatusOK},
+		{"customerio without body", NewCustomerIO("key"), "/v1/send/email", bearer,
+			`{"identifiers":{"id":"1"},"to":"t@x.com","from":"f@x.com","subject":"s","plaintext_body":"v"}`, http.StatusBadRequest},
+		{"customerio transactional", NewCustomerIO("key"), "/v1/send/email", bearer,
+			`{"identifiers":{"id":"1"},"to":"t@x.com","transactional_message_id":"7"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer tt.srv.Close()
+			if got := post(t, tt.srv, tt.path, tt.body, tt.auth); got != tt.status {
+				t.Errorf("got status %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	body := `{"from":{"email":"f@x.com"},"personalizations":[{"to":[{"email":"t@x.com"}],"subject":"s","custom_args":{"id":"1"}}],"content":[{"type":"text/plain","value":"v"}]}`
+	rec := NewSendGrid("key")
+	post(t, rec, "/mail/send", body, bearer)
+	rec.Close()
+
+	b := &bytes.Buffer{}
+	if err := rec.Save(b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "Bearer key") {
+		t.Error("the credentials were saved")
+	}
+	recording, err := Load(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recording[0].Response.Header.Set("X-Message-Id", "recorded")
+
+	srv := NewSendGrid("key").Replay(recording, "personalizations.*.custom_args")
+	defer srv.Close()
+	changed := strings.Replace(body, `"id":"1"`, `"id":"2"`, 1)
+	if got := post(t, srv, "/mail/send", changed, bearer); got != http.StatusAccepted {
+		t.Errorf("an ignored field fails the replay with %d", got)
+	}
+	if got := srv.Interactions()[0].Response.Header.Get("X-Message-Id"); got != "recorded" {
+		t.Error("the recorded response was not replayed")
+	}
+	if got := post(t, srv, "/mail/send", body, bearer); got != http.StatusBadRequest {
+		t.Errorf("a request after the recording got %d", got)
+	}
+
+	srv.Reset()
+	changed = strings.Replace(body, `"value":"v"`, `"value":"w"`, 1)
+	if got := post(t, srv, "/mail/send", changed, bearer); got != http.StatusBadRequest {
+		t.Errorf("a changed payload got %d", got)
+	}
+}
+
+// postFiles send a mailgun multipart message with the files in the given order,
+// each file is a field, a file name and a content
+func postFiles(t *testing.T, srv *Server, files [][3]string) int {
+	t.Helper()
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for _, f := range files {
+		part, _ := w.CreateFormFile(f[0], f[1])
+		part.Write([]byte(f[2]))
+	}
+	w.WriteField("from", "f@x.com")
+	w.WriteField("to", "t@x.com")
+	w.WriteField("subject", "s")
+	w.WriteField("text", "v")
+	w.Close()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/example.com/messages", body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.SetBasicAuth("api", "key")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestReplayFiles(t *testing.T) {
+	files := [][3]string{
+		{"attachment[0]", "a.txt", "a"},
+		{"attachment[1]", "b.txt", "b"},
+		{"attachment[2]", "c.txt", "c"},
+		{"inline[0]", "logo.png", "png"},
+	}
+	rec := NewMailgun("key", "example.com")
+	postFiles(t, rec, files)
+	rec.Close()
+
+	// the parts arrive in another order, the recording must still match
+	reversed := [][3]string{}
+	for i := len(files) - 1; i >= 0; i-- {
+		reversed = append(reversed, files[i])
+	}
+	for i := 0; i < 10; i++ {
+		srv := NewMailgun("key", "example.com").Replay(rec.Interactions())
+		if got := postFiles(t, srv, reversed); got != http.StatusOK {
+			t.Fatalf("the same files in another order got %d", got)
+		}
+		srv.Close()
+	}
+
+	srv := NewMailgun("key", "example.com").Replay(rec.Interactions())
+	defer srv.Close()
+	changed := append([][3]string{}, files...)
+	changed[1] = [3]string{"attachment[1]", "b.txt", "changed"}
+	if got := postFiles(t, srv, changed); got != http.StatusBadRequest {
+		t.Errorf("a changed file got %d", got)
+	}
+}
@@ -1,3 +1,151 @@
 package gomailer
 
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
 // https://jangomail.com/send-email-via-api/
+// https://api.jangomail.com/api.asmx?op=SendTransactionalEmail
+
+const (
+	// jangomailBaseURL describes jangomail mail sending api base url
+	jangomailBaseURL = "https://api.jangomail.com/api.asmx"
+)
+
+type (
+	// jangomail describes a jangomail type
+	jangomail struct {
+		c       client
+		configs Configs
+	}
+
+	// jangomailResponse describes the xml string jangomail responds with
+	jangomailResponse struct {
+		Value string `xml:",chardata"`
+	}
+)
+
+// messageURL return a message url
+func (j *jangomail) messageURL() string {
+	url := jangomailBaseURL
+	if j.configs.BaseURL != "" {
+		url = j.configs.BaseURL
+	}
+	return fmt.Sprintf("%s/SendTransactionalEmail", url)
+}
+
+// Send process an email sending and return the provider response
+func (j *jangomail) Send(ctx context.Context, msg *Message) (*SendResult, error) {
+	// the provider api used by the driver renders no hosted templates
+	if err := unsupportedTemplate("jangomail", msg); err != nil {
+		return nil, err
+	}
+
+	// verify params for sending email
+	if err := j.verifyParams(msg); err != nil {
+		return nil, err
+	}
+
+	// transactional emails go to a single address and can only reference
+	// files already uploaded to the jangomail account
+	features := []string{}
+	if len(msg.To) > 1 {
+		features = append(features, "multiple To receipents")
+	}
+	if msg.hasAttachments(false) {
+		features = append(features, "attachments")
+	}
+	if msg.hasAttachments(true) {
+		features = append(features, "inline attachments")
+	}
+	if len(features) > 0 {
+		return nil, &unsupportedError{service: "jangomail", features: features}
+	}
+
+	// cc, bcc and reply-to travel in the comma separated Options field
+	options := []string{}
+	if len(msg.Cc) > 0 {
+		options = append(options, "CC="+j.lists(msg.Cc))
+	}
+	if len(msg.Bcc) > 0 {
+		options = append(options, "BCC="+j.lists(msg.Bcc))
+	}
+	if msg.ReplyTo.Email != "" {
+		options = append(options, "ReplyTo="+msg.ReplyTo.Email)
+	}
+
+	// build params, the api expects every field even if it is empty
+	params := url.Values{}
+	params.Set("Username", j.configs.Username)
+	params.Set("Password", j.configs.Password)
+	params.Set("FromEmail", msg.From.Email)
+	params.Set("FromName", msg.From.Name)
+	params.Set("ToEmailAddress", msg.To[0].Email)
+	params.Set("Subject", msg.Subject)
+	params.Set("MessagePlain", msg.Text)
+	params.Set("MessageHTML", msg.HTML)
+	params.Set("Options", strings.Join(options, ","))
+
+	// wait for the client side rate limiter if any
+	ctx, err := j.c.limiter.wait(ctx, "jangomail", msg.receipents())
+	if err != nil {
+		return nil, err
+	}
+
+	return j.processJangomailRequest(ctx, msg, params)
+}
+
+// lists return a semicolon separated list of email addresses
+func (jangomail) lists(a []Address) string {
+	list := []string{}
+	for _, v := range a {
+		list = append(list, v.Email)
+	}
+	return strings.Join(list, ";")
+}
+
+// verifyParams verify the required params
+func (j jangomail) verifyParams(msg *Message) error {
+	v := validation{service: "jangomail"}
+	if j.configs.Username == "" ||
+		j.configs.Password == "" {
+		v.add(ErrMissingCredentials, "for jangomail you must provide Username and Password in config")
+	}
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), 0)
+	v.verifyBody(msg)
+	return v.err()
+}
+
+// processJangomailRequest perform a form post request for jangomail
+func (j *jangomail) processJangomailRequest(ctx context.Context, msg *Message, params url.Values) (*SendResult, error) {
+	status, body, err := j.c.postForm(ctx, j.messageURL(), params)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, newProviderError("jangomail", status, body, "", "")
+	}
+
+	// the response is an xml string of newline separated values, the
+	// first one is the result code where 0 means success
+	result := jangomailResponse{}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(result.Value), "\n")
+	if code := strings.TrimSpace(lines[0]); code != "0" {
+		return nil, newProviderError("jangomail", status, body, code, strings.Join(lines[1:], " "))
+	}
+	// the third value is the transaction id
+	r := newSendResult("jangomail", status, body, msg.To, msg.Cc, msg.Bcc)
+	if len(lines) > 2 {
+		r.MessageIDs = []string{strings.TrimSpace(lines[2])}
+	}
+	return r, nil
+}
@@ -0,0 +1,36 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJangomailAccepted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<string>0\nSUCCESS\n1234</string>"))
+	}))
+	defer srv.Close()
+
+	s, _ := NewSender(JANGOMAIL, Configs{Username: "user", Password: "pass", BaseURL: srv.URL})
+	res, err := s.Send(context.Background(), &Message{
+		From:    Address{Email: "john@example.com"},
+		To:      []Address{{Email: "jane@example.com"}},
+		Cc:      []Address{{Email: "cc@example.com"}},
+		Bcc:     []Address{{Email: "bcc@example.com"}},
+		Subject: "subject",
+		Text:    "text",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"jane@example.com", "cc@example.com", "bcc@example.com"}
+	if fmt.Sprint(res.Accepted) != fmt.Sprint(want) {
+		t.Errorf("got accepted %v, want %v", res.Accepted, want)
+	}
+	if res.MessageID() != "1234" {
+		t.Errorf("got message id %q, want 1234", res.MessageID())
+	}
+}
@@ -1,3 +1,140 @@
 package gomailer
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
 // http://dev.leadersend.com/
+
+const (
+	// leadersendBaseURL describes leadersend mail sending api base url
+	leadersendBaseURL = "https://api.leadersend.com/1.0"
+	// leadersendMaxReceipents describes the max receipents per email
+	leadersendMaxReceipents = 1000
+)
+
+type (
+	// leadersend describes a leadersend type
+	leadersend struct {
+		c       client
+		configs Configs
+	}
+
+	// leadersendResponse describes the leadersend error envelope
+	leadersendResponse struct {
+		Status  string `json:"status"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+)
+
+// messageURL return a message url
+func (l *leadersend) messageURL() string {
+	url := leadersendBaseURL
+	if l.configs.BaseURL != "" {
+		url = l.configs.BaseURL
+	}
+	return fmt.Sprintf("%s/messages/send", url)
+}
+
+// Send process an email sending and return the provider response
+func (l *leadersend) Send(ctx context.Context, msg *Message) (*SendResult, error) {
+	// the provider api used by the driver renders no hosted templates
+	if err := unsupportedTemplate("leadersend", msg); err != nil {
+		return nil, err
+	}
+
+	// verify params for sending email
+	if err := l.verifyParams(msg); err != nil {
+		return nil, err
+	}
+
+	// leadersend sends a separate copy to every To receipent and has no file support
+	features := []string{}
+	if len(msg.Cc) > 0 {
+		features = append(features, "Cc")
+	}
+	if len(msg.Bcc) > 0 {
+		features = append(features, "Bcc")
+	}
+	if msg.hasAttachments(false) {
+		features = append(features, "attachments")
+	}
+	if msg.hasAttachments(true) {
+		features = append(features, "inline attachments")
+	}
+	if len(features) > 0 {
+		return nil, &unsupportedError{service: "leadersend", features: features}
+	}
+
+	// build params, nested values are sent in the bracket notation
+	params := url.Values{}
+	params.Set("apikey", l.configs.APIKey)
+	params.Set("message[subject]", msg.Subject)
+	params.Set("message[from][email]", msg.From.Email)
+	params.Set("message[from][name]", msg.From.Name)
+	for i, a := range msg.To {
+		params.Set(fmt.Sprintf("message[to][%d][email]", i), a.Email)
+		params.Set(fmt.Sprintf("message[to][%d][name]", i), a.Name)
+	}
+	if msg.ReplyTo.Email != "" {
+		params.Set("message[headers][Reply-To]", msg.ReplyTo.format())
+	}
+	if msg.HTML != "" {
+		params.Set("message[html]", msg.HTML)
+	}
+	if msg.Text != "" {
+		params.Set("message[text]", msg.Text)
+	}
+
+	// wait for the client side rate limiter if any
+	ctx, err := l.c.limiter.wait(ctx, "leadersend", msg.receipents())
+	if err != nil {
+		return nil, err
+	}
+
+	return l.processLeadersendRequest(ctx, msg, params)
+}
+
+// maxReceipents return the max to, cc and bcc receipents per email
+func (leadersend) maxReceipents() int {
+	return leadersendMaxReceipents
+}
+
+// verifyParams verify the required params
+func (l leadersend) verifyParams(msg *Message) error {
+	v := validation{service: "leadersend"}
+	if l.configs.APIKey == "" {
+		v.add(ErrMissingCredentials, "for leadersend you must provide APIKey in config")
+	}
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), leadersendMaxReceipents)
+	v.verifyBody(msg)
+	return v.err()
+}
+
+// processLeadersendRequest perform a form post request for leadersend
+func (l *leadersend) processLeadersendRequest(ctx context.Context, msg *Message, params url.Values) (*SendResult, error) {
+	status, body, err := l.c.postForm(ctx, l.messageURL(), params)
+	if err != nil {
+		return nil, err
+	}
+
+	// errors come back as a json envelope with status "error"
+	result := leadersendResponse{}
+	_ = json.Unmarshal(body, &result)
+	if result.Status == "error" || status != http.StatusOK {
+		code := ""
+		if result.Code != 0 {
+			code = strconv.Itoa(result.Code)
+		}
+		return nil, newProviderError("leadersend", status, body, code, result.Message)
+	}
+	// leadersend does not return message ids
+	return newSendResult("leadersend", status, body, msg.To), nil
+}
@@ -1,3 +1,143 @@
 package gomailer
 
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
 // https://madmimi.com/api/v3/docs
+// https://madmimi.com/developer/mailer/transactional
+
+const (
+	// madmimiBaseURL describes madmimi mail sending api base url
+	madmimiBaseURL = "https://api.madmimi.com"
+	// madmimiTrackingBeacon describes the placeholder madmimi requires in every html body
+	madmimiTrackingBeacon = "[[tracking_beacon]]"
+)
+
+// madmimi describes a madmimi type
+type madmimi struct {
+	c       client
+	configs Configs
+}
+
+// messageURL return a message url
+func (m *madmimi) messageURL() string {
+	url := madmimiBaseURL
+	if m.configs.BaseURL != "" {
+		url = m.configs.BaseURL
+	}
+	return fmt.Sprintf("%s/mailer", url)
+}
+
+// Send process an email sending and return the provider response
+func (m *madmimi) Send(ctx context.Context, msg *Message) (*SendResult, error) {
+	// verify params for sending email
+	if err := m.verifyParams(msg); err != nil {
+		return nil, err
+	}
+
+	// the mailer api delivers to a single receipent and has no file support
+	features := []string{}
+	if len(msg.To) > 1 {
+		features = append(features, "multiple To receipents")
+	}
+	if len(msg.Cc) > 0 {
+		features = append(features, "Cc")
+	}
+	if len(msg.Bcc) > 1 {
+		features = append(features, "multiple Bcc receipents")
+	}
+	if msg.hasAttachments(false) {
+		features = append(features, "attachments")
+	}
+	if msg.hasAttachments(true) {
+		features = append(features, "inline attachments")
+	}
+	if len(features) > 0 {
+		return nil, &unsupportedError{service: "madmimi", features: features}
+	}
+
+	// build params
+	params := url.Values{}
+	params.Set("username", m.configs.Username)
+	params.Set("api_key", m.configs.APIKey)
+	// madmimi groups transactional mails by promotion, the subject is used to
+	// name it. A template is an existing promotion whose {placeholders} are
+	// filled from the yaml body, json being valid yaml
+	params.Set("promotion_name", msg.Subject)
+	if msg.Template != "" {
+		params.Set("promotion_name", msg.Template)
+		if len(msg.TemplateData) > 0 {
+			data, err := toJSON(msg.TemplateData)
+			if err != nil {
+				return nil, newLocalError("madmimi", err)
+			}
+			params.Set("body", string(data))
+		}
+	}
+	params.Set("recipient", msg.To[0].format())
+	params.Set("from", msg.From.format())
+	if msg.Template == "" || msg.Subject != "" {
+		params.Set("subject", msg.Subject)
+	}
+
+	if len(msg.Bcc) > 0 {
+		params.Set("bcc", msg.Bcc[0].Email)
+	}
+	if msg.ReplyTo.Email != "" {
+		params.Set("reply_to", msg.ReplyTo.Email)
+	}
+	if msg.HTML != "" {
+		// madmimi rejects html bodies without the tracking beacon
+		html := msg.HTML
+		if !strings.Contains(html, madmimiTrackingBeacon) {
+			html += madmimiTrackingBeacon
+		}
+		params.Set("raw_html", html)
+	}
+	if msg.Text != "" {
+		params.Set("raw_plain_text", msg.Text)
+	}
+
+	// wait for the client side rate limiter if any
+	ctx, err := m.c.limiter.wait(ctx, "madmimi", msg.receipents())
+	if err != nil {
+		return nil, err
+	}
+
+	return m.processMadmimiRequest(ctx, msg, params)
+}
+
+// verifyParams verify the required params
+func (m madmimi) verifyParams(msg *Message) error {
+	v := validation{service: "madmimi"}
+	if m.configs.Username == "" ||
+		m.configs.APIKey == "" {
+		v.add(ErrMissingCredentials, "for madmimi you must provide Username and APIKey in config")
+	}
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), 0)
+	v.verifyBody(msg)
+	return v.err()
+}
+
+// processMadmimiRequest perform a form post request for madmimi
+func (m *madmimi) processMadmimiRequest(ctx context.Context, msg *Message, params url.Values) (*SendResult, error) {
+	status, body, err := m.c.postForm(ctx, m.messageURL(), params)
+	if err != nil {
+		return nil, err
+	}
+	// on success madmimi responds with the transaction id in plain text
+	if status != http.StatusOK {
+		return nil, newProviderError("madmimi", status, body, "", "")
+	}
+	r := newSendResult("madmimi", status, body, msg.To, msg.Bcc)
+	if id := strings.TrimSpace(string(body)); id != "" {
+		r.MessageIDs = []string{id}
+	}
+	return r, nil
+}
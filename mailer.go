@@ -2,14 +2,12 @@
 package gomailer
 
 import (
-	"bytes"
-	b64 "encoding/base64"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"mime"
-	"path/filepath"
+	"net/http"
 	"time"
 )
 
@@ -27,18 +25,35 @@ const (
 	MAILJET
 	// CUSTOMERIO driver
 	CUSTOMERIO
+	// SPARKPOST driver
+	SPARKPOST
+	// MANDRILL driver
+	MANDRILL
+	// SOCKETLABS driver
+	SOCKETLABS
+	// ELASTICEMAIL driver
+	ELASTICEMAIL
+	// POSTAGEAPP driver
+	POSTAGEAPP
+	// MADMIMI driver
+	MADMIMI
+	// JANGOMAIL driver
+	JANGOMAIL
+	// LEADERSEND driver
+	LEADERSEND
+	// SMTP driver
+	SMTP
+	// SES driver
+	SES
+	// MEMORY driver keeps messages in an Outbox, for tests
+	MEMORY
+	// FILE driver writes messages as .eml files, for development
+	FILE
 )
 
 type (
 	// mapData represents custom data type for mailer
 	mapData map[string]interface{}
-	// address describes an email address
-	address struct {
-		Name  string `json:"name"`
-		Email string `json:"email"`
-		Type  string `json:"type,omitempty"`
-	}
-
 	// attachment describes an email attachment
 	attachment struct {
 		Content     string `json:"content"`
@@ -51,16 +66,32 @@ type (
 
 	// Configs represents the configurations
 	Configs struct {
-		ServerToken    string        // ServerToken for service like postmarkapp
-		AccountToken   string        // AccountToken for service like postmarkapp
-		APIKey         string        // APIKey represents the API key for mail service like mailgun
-		PrivateKey     string        // PrivateKey represents  the PrivateKey provided by service like mailjet
-		PublicKey      string        // PublicKey represents  the PublicKey provided by service like mailjet
-		BaseURL        string        // BaseURL represents the base url for service
-		Domain         string        // Domain represents the domain of the service
-		Username       string        // Username represents the username for service
-		Password       string        // Password represents the password for service
-		RequestTimeout time.Duration // RequestTimeout represents the timeout for http client call
+		ServerToken    string            // ServerToken for service like postmarkapp
+		AccountToken   string            // AccountToken for service like postmarkapp
+		APIKey         string            // APIKey represents the API key for mail service like mailgun
+		PrivateKey     string            // PrivateKey represents  the PrivateKey provided by service like mailjet
+		PublicKey      string            // PublicKey represents  the PublicKey provided by service like mailjet
+		BaseURL        string            // BaseURL represents the base url for service
+		Domain         string            // Domain represents the domain of the service
+		ServerID       string            // ServerID represents the server id for service like socketlabs
+		Username       string            // Username represents the username for service
+		Password       string            // Password represents the password for service
+		Host           string            // Host represents the server host for service like smtp
+		Port           int               // Port represents the server port for service like smtp, 465 uses implicit TLS
+		SMTPAuth       string            // SMTPAuth represents the smtp auth mechanism, PLAIN (default), LOGIN or CRAM-MD5
+		TLSConfig      *tls.Config       // TLSConfig represents the tls config for service like smtp
+		RequireTLS     bool              // RequireTLS represents refusing a smtp server which does not offer STARTTLS, instead of sending in cleartext
+		HTTPClient     *http.Client      // HTTPClient represents a custom http client, Transport and RequestTimeout are ignored when set
+		Transport      http.RoundTripper // Transport represents a custom round tripper for proxies, mTLS or test doubles
+		AccessKey      string            // AccessKey represents the access key id for service like ses
+		SecretKey      string            // SecretKey represents the secret access key for service like ses
+		SessionToken   string            // SessionToken represents the temporary session token for service like ses
+		Region         string            // Region represents the region of the service like ses
+		RequestTimeout time.Duration     // RequestTimeout represents the timeout for http client call, retries included
+		Retry          RetryPolicy       // Retry represents the retry policy for transient failures, disabled by default
+		Outbox         *Outbox           // Outbox represents the outbox of the memory driver, DefaultOutbox when nil
+		Directory      string            // Directory represents the output directory of the file driver
+		RateLimit      RateLimit         // RateLimit represents the client side rate limit shared by every mailer of the driver and account, disabled by default
 	}
 
 	// represents the driver type
@@ -84,6 +115,16 @@ type (
 		BodyHTML(html string) Mailer
 		// BodyText sets plain text body for an email
 		BodyText(text string) Mailer
+		// Tag adds a tag to an email, used by a Router to pick the sender
+		Tag(tag string) Mailer
+		// ID sets a stable identity of the email so providers which drop duplicates recognise a resend
+		ID(id string) Mailer
+		// Template sets a template hosted by the provider, drivers without hosted templates return ErrUnsupported
+		Template(id string, data map[string]interface{}) Mailer
+		// Render sets a template rendering the subject and bodies with data
+		Render(t *Templates, name string, data interface{}) Mailer
+		// RenderFor sets the template data of a To receipent, who gets an email of their own
+		RenderFor(email string, data interface{}) Mailer
 		// AttachmentFile sets email attachments from file name on disk
 		AttachmentFile(file string) Mailer
 		// AttachmentInlineFile sets email inline attachments from file name on disk
@@ -94,106 +135,146 @@ type (
 		AttachmentInlineReader(file string, r io.Reader) Mailer
 		// Send process an email sending
 		Send() error
+		// SendContext process an email sending, cancellation and deadline of ctx apply to the request
+		SendContext(ctx context.Context) error
+		// SendWithResult process an email sending and return the provider message id(s) and receipent status
+		SendWithResult(ctx context.Context) (*SendResult, error)
+		// SendBatch process a batch sending, every receipent gets an email of their own with their data
+		SendBatch(ctx context.Context, recipients ...Recipient) (*BatchResult, error)
+		// Message return the provider neutral message built so far
+		Message() (*Message, error)
+		// Messages return the messages to send, one per receipent with template data
+		Messages() ([]*Message, error)
 	}
 )
 
 // format return a formatted email string
-func (a address) format() string {
+func (a Address) format() string {
 	return fmt.Sprintf("%s <%s>", a.Name, a.Email)
 }
 
-// ReadFromFile read from valid file path
-// encode the file into base64
-func (a *attachment) ReadFromFile(path string) error {
-	b, err := ioutil.ReadFile(path)
+// New Return a new mail driver
+func New(d driver, c Configs) (Mailer, error) {
+	s, err := NewSender(d, c)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	// encode file into base64
-	sEnc := b64.StdEncoding.EncodeToString(b)
-	//extract mime type from file
-	ext := filepath.Ext(path)
-	mType := mime.TypeByExtension(ext)
-	// get file name
-	fileName := filepath.Base(path)
-	// modify the receiver
-	a.Type = mType
-	a.Content = sEnc
-	a.FileName = fileName
-	a.ContentID = fileName
-	return nil
+	return NewMailer(s), nil
 }
 
-// ReadFromFile read from valid file path
-// encode the file into base64
-func (a *attachment) ReadFromReader(f string, sri readerInfo) error {
-	b := &bytes.Buffer{}
-	size, err := io.Copy(b, sri.r)
-	if err != nil {
-		return err
-	}
-	a.Size = size
-	// encode file into base64
-	sEnc := b64.StdEncoding.EncodeToString(b.Bytes())
-	//extract mime type from file
-	ext := filepath.Ext(f)
-	mType := mime.TypeByExtension(ext)
-	// get file name
-	fileName := filepath.Base(f)
-	// modify the receiver
-	a.Type = mType
-	a.Content = sEnc
-	a.FileName = fileName
-	a.ContentID = fileName
-	return nil
+// NewMailer return a Mailer building messages for any sender, such as a Failover
+func NewMailer(s Sender) Mailer {
+	return &builder{sender: s}
 }
 
-// New Return a new mail driver
-func New(d driver, c Configs) (Mailer, error) {
+// NewSender return a driver which sends a Message built elsewhere
+func NewSender(d driver, c Configs) (Sender, error) {
 	return mailFactory(d, c)
 }
 
 // mailFactory return an email type depending on driver
-func mailFactory(d driver, c Configs) (Mailer, error) {
+func mailFactory(d driver, c Configs) (Sender, error) {
 	switch d {
 	case MAILGUN:
 		return &mailgun{
 			configs: c,
-			c: client{
-				timeOut: c.RequestTimeout,
-			},
+			c:       newClient(d, c),
 		}, nil
 
 	case MAILJET:
 		return &mailjet{
 			configs: c,
-			c: client{
-				timeOut: c.RequestTimeout,
-			},
+			c:       newClient(d, c),
 		}, nil
 
 	case SENDGRID:
 		return &sendgrid{
 			configs: c,
-			c: client{
-				timeOut: c.RequestTimeout,
-			},
+			c:       newClient(d, c),
 		}, nil
 
 	case POSTMARK:
 		return &postmark{
 			configs: c,
-			c: client{
-				timeOut: c.RequestTimeout,
-			},
+			c:       newClient(d, c),
 		}, nil
 
 	case CUSTOMERIO:
 		return &customerio{
 			configs: c,
-			c: client{
-				timeOut: c.RequestTimeout,
-			},
+			c:       newClient(d, c),
+		}, nil
+
+	case SPARKPOST:
+		return &sparkpost{
+			configs: c,
+			c:       newClient(d, c),
+		}, nil
+
+	case MANDRILL:
+		return &mandrill{
+			configs: c,
+			c:       newClient(d, c),
+		}, nil
+
+	case SOCKETLABS:
+		return &socketlabs{
+			configs: c,
+			c:       newClient(d, c),
+		}, nil
+
+	case ELASTICEMAIL:
+		return &elasticemail{
+			configs: c,
+			c:       newClient(d, c),
+		}, nil
+
+	case POSTAGEAPP:
+		return &postageapp{
+			configs: c,
+			c:       newClient(d, c),
+		}, nil
+
+	case MADMIMI:
+		return &madmimi{
+			configs: c,
+			c:       newClient(d, c),
+		}, nil
+
+	case JANGOMAIL:
+		return &jangomail{
+			configs: c,
+			c:       newClient(d, c),
+		}, nil
+
+	case LEADERSEND:
+		return &leadersend{
+			configs: c,
+			c:       newClient(d, c),
+		}, nil
+
+	case SMTP:
+		return &smtpMailer{
+			configs: c,
+			c:       newClient(d, c),
+		}, nil
+
+	case SES:
+		return &ses{
+			configs: c,
+			c:       newClient(d, c),
+		}, nil
+
+	case MEMORY:
+		return &memory{
+			configs: c,
+			c:       newClient(d, c),
+		}, nil
+
+	case FILE:
+		return &file{
+			configs: c,
+			c:       newClient(d, c),
 		}, nil
 
 	default:
@@ -2,15 +2,12 @@ package gomailer
 
 import (
 	"bytes"
-	"errors"
+	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"log"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 )
 
@@ -25,22 +22,12 @@ const (
 
 // mailgun describes a mailgun type
 type mailgun struct {
-	c                     client
-	configs               Configs
-	from                  address
-	toList                []address
-	ccList                []address
-	bccList               []address
-	replyTo               address
-	subject               string
-	bodyHTML              string
-	bodyText              string
-	attachmentFiles       []string
-	attachmentInlineFiles []string
+	c       client
+	configs Configs
 }
 
 // lists return a formatted email list comma separate string
-func (mailgun) lists(a []address) string {
+func (mailgun) lists(a []Address) string {
 	if len(a) <= 0 {
 		return ""
 	}
@@ -57,174 +44,193 @@ func (m *mailgun) messageURL() string {
 	if m.configs.BaseURL != "" {
 		url = m.configs.BaseURL
 	}
-	if m.configs.Domain == "" {
-		panic("gomailer: you must provide domain name in Config")
-	}
 	return fmt.Sprintf("%s/%s/messages", url, m.configs.Domain)
 }
 
-// From sets an email sender address
-func (m *mailgun) From(name, from string) Mailer {
-	m.from = address{Name: name, Email: from}
-	return m
-}
-
-// To sets receipents of an email
-func (m *mailgun) To(name, to string) Mailer {
-	m.toList = append(m.toList, address{Name: name, Email: to})
-	return m
-}
-
-// Cc sets Cc receipents of an email
-func (m *mailgun) Cc(name, to string) Mailer {
-	m.ccList = append(m.ccList, address{Name: name, Email: to})
-	return m
-}
-
-// Bcc sets Bcc receipents of an email
-func (m *mailgun) Bcc(name, to string) Mailer {
-	m.bccList = append(m.bccList, address{Name: name, Email: to})
-	return m
-}
-
-// ReplyTo sets the reply-to address of an email
-func (m *mailgun) ReplyTo(name, email string) Mailer {
-	m.replyTo = address{Name: name, Email: email}
-	return m
-}
+// Send process an email sending and return the provider response
+func (m *mailgun) Send(ctx context.Context, msg *Message) (*SendResult, error) {
+	// verify params for sending email
+	if err := m.verifyParams(msg); err != nil {
+		return nil, err
+	}
 
-// Subject sets subject of an email
-func (m *mailgun) Subject(subject string) Mailer {
-	m.subject = subject
-	return m
-}
+	params, attachments, err := m.params(msg)
+	if err != nil {
+		return nil, err
+	}
 
-// BodyHTML sets html body for an email
-func (m *mailgun) BodyHTML(body string) Mailer {
-	m.bodyHTML = body
-	return m
-}
+	// wait for the client side rate limiter if any
+	ctx, err = m.c.limiter.wait(ctx, "mailgun", msg.receipents())
+	if err != nil {
+		return nil, err
+	}
 
-// BodyText sets plain text email body for an email
-func (m *mailgun) BodyText(body string) Mailer {
-	m.bodyText = body
-	return m
+	return m.processMailgunRequest(ctx, msg, params, attachments)
 }
 
-// AttachmentFile set email attachments
-func (m *mailgun) AttachmentFile(file string) Mailer {
-	m.attachmentFiles = append(m.attachmentFiles, file)
-	return m
-}
+// params return the form fields and files of an email
+func (m *mailgun) params(msg *Message) (map[string]string, []Attachment, error) {
+	// build params
+	params := map[string]string{
+		"from": msg.From.format(),
+		"to":   m.lists(msg.To),
+	}
 
-// AttachmentInlineFile set email inline attachment
-func (m *mailgun) AttachmentInlineFile(file string) Mailer {
-	m.attachmentInlineFiles = append(m.attachmentInlineFiles, file)
-	return m
-}
+	// a template brings its own subject unless one is given
+	if msg.Template == "" || msg.Subject != "" {
+		params["subject"] = msg.Subject
+	}
+	if msg.Template != "" {
+		params["template"] = msg.Template
+		if len(msg.TemplateData) > 0 {
+			vars, err := toJSON(msg.TemplateData)
+			if err != nil {
+				return nil, nil, newLocalError("mailgun", err)
+			}
+			params["h:X-Mailgun-Variables"] = strings.TrimSpace(string(vars))
+		}
+	}
+	if len(msg.Cc) > 0 {
+		params["cc"] = m.lists(msg.Cc)
+	}
+	if len(msg.Bcc) > 0 {
+		params["bcc"] = m.lists(msg.Bcc)
+	}
+	if msg.ReplyTo.Email != "" {
+		params["h:Reply-To"] = msg.ReplyTo.format()
+	}
+	if msg.Text != "" {
+		params["text"] = msg.Text
+	}
+	if msg.HTML != "" {
+		params["html"] = msg.HTML
+	}
 
-// AttachmentReader set email attachments
-func (m *mailgun) AttachmentReader(file string, r io.Reader) Mailer {
-	log.Println("gomailer: not implemented")
-	return m
+	return params, msg.Attachments, nil
 }
 
-// AttachmentInlineReader set email inline attachment
-func (m *mailgun) AttachmentInlineReader(file string, r io.Reader) Mailer {
-	log.Println("gomailer: not implemented")
-	return m
+// batchSize return the max receipents of a batch message
+func (mailgun) batchSize() int {
+	return mailgunMaxReceipents
 }
 
-// Send process an email sending
-func (m *mailgun) Send() error {
-	// verify params for sending email
-	m.verifyParams()
-
-	//check the total file size
-	var totalSize int64
-	for _, f := range m.attachmentFiles {
-		fi, e := os.Stat(f)
-		if e != nil {
-			return e
-		}
-		// get the size
-		totalSize += fi.Size()
+// sendBatch send a batch message, mailgun sends a separate copy to every
+// receipent listed in the recipient-variables. Placeholders become
+// %recipient.key%, for a hosted template the template variables of the
+// receipent keys reference them so the receipent data is merged over the
+// template data, as text
+func (m *mailgun) sendBatch(ctx context.Context, msg *Message, recipients []Recipient) ([]RecipientResult, error) {
+	batch := msg.clone()
+	batch.To = batchAddresses(recipients)
+
+	// every receipent needs every key, a missing one would be sent verbatim
+	keys := batchKeys(recipients)
+	pairs := []string{}
+	for _, k := range keys {
+		pairs = append(pairs, placeholder(k), "%recipient."+k+"%")
 	}
-
-	if totalSize > mailgunMaxFileSize {
-		return errors.New("gomailer: max attachment size for mailgun is 25MB")
+	if len(pairs) > 0 {
+		r := strings.NewReplacer(pairs...)
+		batch.Subject = r.Replace(batch.Subject)
+		batch.HTML = r.Replace(batch.HTML)
+		batch.Text = r.Replace(batch.Text)
 	}
-
-	// build params
-	params := map[string]string{
-		"from":    m.from.format(),
-		"to":      m.lists(m.toList),
-		"subject": m.subject,
+	if batch.Template != "" && len(keys) > 0 {
+		if batch.TemplateData == nil {
+			batch.TemplateData = map[string]interface{}{}
+		}
+		for _, k := range keys {
+			batch.TemplateData[k] = "%recipient." + k + "%"
+		}
 	}
-	if len(m.ccList) > 0 {
-		params["cc"] = m.lists(m.ccList)
+	vars := map[string]map[string]interface{}{}
+	for _, r := range recipients {
+		v := map[string]interface{}{}
+		for _, k := range keys {
+			// a receipent without the key keeps the template value
+			v[k] = ""
+			if d, ok := msg.TemplateData[k]; ok {
+				v[k] = d
+			}
+		}
+		for k, val := range r.Data {
+			v[k] = val
+		}
+		vars[r.Address.Email] = v
 	}
-	if len(m.bccList) > 0 {
-		params["bcc"] = m.lists(m.bccList)
+	recipientVars, err := toJSON(vars)
+	if err != nil {
+		return nil, newLocalError("mailgun", err)
 	}
-	if m.replyTo.Email != "" {
-		params["h:Reply-To"] = m.replyTo.format()
+
+	params, attachments, err := m.params(batch)
+	if err != nil {
+		return nil, err
 	}
-	if m.bodyText != "" {
-		params["text"] = m.bodyText
+	params["recipient-variables"] = strings.TrimSpace(string(recipientVars))
+
+	// wait for the client side rate limiter if any
+	ctx, err = m.c.limiter.wait(ctx, "mailgun", batch.receipents())
+	if err != nil {
+		return nil, err
 	}
-	if m.bodyHTML != "" {
-		params["html"] = m.bodyHTML
+
+	res, err := m.processMailgunRequest(ctx, batch, params, attachments)
+	if err != nil {
+		return nil, err
 	}
+	// mailgun returns a single message id per request
+	return batchResults(recipients, res.MessageID()), nil
+}
 
-	// build attachments for both inline and general attachments
-	attachments := map[string][]string{}
-	attachments["attachment"] = m.attachmentFiles
-	attachments["inline"] = m.attachmentInlineFiles
+// maxAttachmentSize return the max total size in bytes of the attachments
+func (mailgun) maxAttachmentSize() int64 {
+	return mailgunMaxFileSize
+}
 
-	return m.processMailgunRequest(params, attachments)
+// maxReceipents return the max to, cc and bcc receipents per email
+func (mailgun) maxReceipents() int {
+	return mailgunMaxReceipents
 }
 
 // verifyParams verify the required params
-func (m mailgun) verifyParams() {
-	if m.from.Email == "" {
-		panic("gomailer: you must provide from")
-	}
-	if len(m.toList) <= 0 {
-		panic("gomailer: you must provide at least one receipent")
-	}
-	if len(m.toList)+len(m.ccList)+len(m.bccList) > mailgunMaxReceipents {
-		panic(fmt.Sprintf("mailer: total number of receipents including to/cc/bcc can not be greater than %d for mailgun", mailgunMaxReceipents))
+func (m mailgun) verifyParams(msg *Message) error {
+	v := validation{service: "mailgun"}
+	if m.configs.APIKey == "" {
+		v.add(ErrMissingCredentials, "for mailgun you must provide APIKey in config")
 	}
-	if m.bodyText == "" && m.bodyHTML == "" {
-		panic("gomailer: you must provide a Text or HTML body")
+	if m.configs.Domain == "" {
+		v.add(ErrInvalidConfig, "you must provide domain name in Config")
 	}
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), mailgunMaxReceipents)
+	v.verifyBody(msg)
+	v.verifyAttachments(msg.attachmentSize(), mailgunMaxFileSize)
+	return v.err()
 }
 
 // processMailgunRequest build a post request for mailgun
-func (m *mailgun) processMailgunRequest(params map[string]string, files map[string][]string) error {
+func (m *mailgun) processMailgunRequest(ctx context.Context, msg *Message, params map[string]string, files []Attachment) (*SendResult, error) {
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 
-	// add files if exist
-	for fileFieldName, fPaths := range files {
-		for index, fPath := range fPaths {
-			file, errF := os.Open(fPath)
-			if errF != nil {
-				return errF
-			}
-			paramName := fmt.Sprintf("%s[%d]", fileFieldName, index)
-			part, err := writer.CreateFormFile(paramName, filepath.Base(fPath))
-			if err != nil {
-				return err
-			}
-			_, err = io.Copy(part, file)
-			if err != nil {
-				return err
-			}
-			if err := file.Close(); err != nil {
-				return err
-			}
+	// add files if exist, in the order of the message so the same message
+	// always builds the same body. Inline and general attachments are
+	// numbered separately
+	index := map[string]int{}
+	for _, a := range files {
+		fileFieldName := "attachment"
+		if a.Inline {
+			fileFieldName = "inline"
+		}
+		paramName := fmt.Sprintf("%s[%d]", fileFieldName, index[fileFieldName])
+		index[fileFieldName]++
+		part, err := writer.CreateFormFile(paramName, a.FileName)
+		if err != nil {
+			return nil, newLocalError("mailgun", err)
+		}
+		if _, err := part.Write(a.Content); err != nil {
+			return nil, newLocalError("mailgun", err)
 		}
 	}
 
@@ -235,10 +241,13 @@ func (m *mailgun) processMailgunRequest(params map[string]string, files map[stri
 
 	err := writer.Close()
 	if err != nil {
-		return err
+		return nil, newLocalError("mailgun", err)
 	}
 
-	req, err := http.NewRequest("POST", m.messageURL(), body)
+	req, err := http.NewRequestWithContext(ctx, "POST", m.messageURL(), body)
+	if err != nil {
+		return nil, newLocalError("mailgun", err)
+	}
 
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 	// do basic auth for mailgun
@@ -247,16 +256,33 @@ func (m *mailgun) processMailgunRequest(params map[string]string, files map[stri
 	// process the post request
 	resp, err := m.c.getDefaultClient().Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	bodyByte, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return errors.New(string(bodyByte))
+		return nil, m.providerError(resp.StatusCode, bodyByte)
+	}
+	result := struct {
+		ID string `json:"id"`
+	}{}
+	_ = json.Unmarshal(bodyByte, &result)
+	r := newSendResult("mailgun", resp.StatusCode, bodyByte, msg.To, msg.Cc, msg.Bcc)
+	if result.ID != "" {
+		r.MessageIDs = []string{result.ID}
 	}
-	return err
+	return r, nil
+}
+
+// providerError decode a mailgun error response
+func (mailgun) providerError(status int, body []byte) error {
+	result := struct {
+		Message string `json:"message"`
+	}{}
+	_ = json.Unmarshal(body, &result)
+	return newProviderError("mailgun", status, body, "", result.Message)
 }
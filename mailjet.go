@@ -2,13 +2,13 @@ package gomailer
 
 import (
 	"bytes"
-	"errors"
+	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
+	"strconv"
+	"strings"
 )
 
 // https://dev.mailjet.com/email-api/v3/apikey/
@@ -20,23 +20,15 @@ const (
 	mailjetMaxFileSize int64 = 15 * 1000000
 	// mailjetMaxReceipents describes the max receipents per email
 	mailjetMaxReceipents = 50
+	// mailjetMaxBatchPayload describes the max size in bytes of a send request
+	mailjetMaxBatchPayload int64 = 15 * 1000000
 )
 
 type (
 	// mailjet describes a mailjet type
 	mailjet struct {
-		c                     client
-		configs               Configs
-		from                  mailjetAddress
-		toList                []mailjetAddress
-		ccList                []mailjetAddress
-		bccList               []mailjetAddress
-		replyTo               mailjetAddress
-		subject               string
-		bodyHTML              string
-		bodyText              string
-		attachmentFiles       []string
-		attachmentInlineFiles []string
+		c       client
+		configs Configs
 	}
 
 	// mailjetAddress represents mailjet address
@@ -51,6 +43,18 @@ type (
 		ContentType string `json:"ContentType"`
 		ContentID   string `json:"ContentID,omitempty"`
 	}
+
+	// mailjetReceipent describes the status of a receipent in the response
+	mailjetReceipent struct {
+		Email       string `json:"Email"`
+		MessageUUID string `json:"MessageUUID"`
+	}
+
+	// mailjetError describes an error in the response
+	mailjetError struct {
+		ErrorCode    string `json:"ErrorCode"`
+		ErrorMessage string `json:"ErrorMessage"`
+	}
 )
 
 // messageURL return a message url
@@ -62,203 +66,228 @@ func (m *mailjet) messageURL() string {
 	return fmt.Sprintf("%s/send", url)
 }
 
-// From sets an email sender address
-func (m *mailjet) From(name, from string) Mailer {
-	m.from = mailjetAddress{Name: name, Email: from}
-	return m
-}
-
-// To sets receipents of an email
-func (m *mailjet) To(name, to string) Mailer {
-	m.toList = append(m.toList, mailjetAddress{Name: name, Email: to})
-	return m
-}
-
-// Cc sets CC receipents of an email
-func (m *mailjet) Cc(name, to string) Mailer {
-	m.ccList = append(m.ccList, mailjetAddress{Name: name, Email: to})
-	return m
-}
-
-// Bcc sets BCC receipents of an email
-func (m *mailjet) Bcc(name, to string) Mailer {
-	m.bccList = append(m.bccList, mailjetAddress{Name: name, Email: to})
-	return m
-}
-
-// ReplyTo sets the reply-to address of an email
-func (m *mailjet) ReplyTo(name, email string) Mailer {
-	m.replyTo = mailjetAddress{Name: name, Email: email}
-	return m
-}
-
-// Subject sets subject of an email
-func (m *mailjet) Subject(subject string) Mailer {
-	m.subject = subject
-	return m
-}
-
-// BodyHTML sets html body for an email
-func (m *mailjet) BodyHTML(body string) Mailer {
-	m.bodyHTML = body
-	return m
-}
-
-// BodyText sets plain text email body for an email
-func (m *mailjet) BodyText(body string) Mailer {
-	m.bodyText = body
-	return m
-}
-
-// AttachmentFile set email attachments
-func (m *mailjet) AttachmentFile(file string) Mailer {
-	m.attachmentFiles = append(m.attachmentFiles, file)
-	return m
-}
-
-// AttachmentInlineFile set email inline attachment
-func (m *mailjet) AttachmentInlineFile(file string) Mailer {
-	m.attachmentInlineFiles = append(m.attachmentInlineFiles, file)
-	return m
-}
-
-// AttachmentReader set email attachments
-func (m *mailjet) AttachmentReader(file string, r io.Reader) Mailer {
-	log.Println("gomailer: not implemented")
-	return m
-}
-
-// AttachmentInlineReader set email inline attachment
-func (m *mailjet) AttachmentInlineReader(file string, r io.Reader) Mailer {
-	log.Println("gomailer: not implemented")
-	return m
-}
-
-// Send process an email sending
-func (m *mailjet) Send() error {
+// Send process an email sending and return the provider response
+func (m *mailjet) Send(ctx context.Context, msg *Message) (*SendResult, error) {
 	// verify params for sending email
-	m.verifyParams()
-
-	//check the total file size and path
-	var totalSize int64
-	totalFiles := []string{}
-	totalFiles = append(totalFiles, m.attachmentFiles...)
-	totalFiles = append(totalFiles, m.attachmentFiles...)
-	for _, f := range totalFiles {
-		fi, e := os.Stat(f)
-		if e != nil {
-			return e
-		}
-		totalSize += fi.Size()
+	if err := m.verifyParams(msg); err != nil {
+		return nil, err
 	}
 
-	if totalSize > mailjetMaxFileSize {
-		return errors.New("gomailer: max attachment size for mailjet is 15MB")
+	body := struct {
+		Messages []mapData `json:"Messages"`
+	}{[]mapData{m.params(msg)}}
+
+	// wait for the client side rate limiter if any
+	ctx, err := m.c.limiter.wait(ctx, "mailjet", msg.receipents())
+	if err != nil {
+		return nil, err
 	}
 
+	return m.processMailjetRequest(ctx, body)
+}
+
+// params return the message of an email in the request body
+func (m *mailjet) params(msg *Message) mapData {
 	// build attachment
 	attachments := []mailjetAttachment{}
-	for _, f := range m.attachmentFiles {
-		a := attachment{}
-		err := a.ReadFromFile(f)
-		if err != nil {
-			return err
-		}
-		attachments = append(attachments, mailjetAttachment{
-			Name:        a.FileName,
-			Content:     a.Content,
-			ContentType: a.Type,
-		})
-	}
-
 	inlinedAttachments := []mailjetAttachment{}
-	for _, f := range m.attachmentInlineFiles {
-		a := attachment{}
-		err := a.ReadFromFile(f)
-		if err != nil {
-			return err
+	for _, f := range msg.Attachments {
+		a := f.encode()
+		if f.Inline {
+			inlinedAttachments = append(inlinedAttachments, mailjetAttachment{
+				Name:        a.FileName,
+				Content:     a.Content,
+				ContentType: a.Type,
+				ContentID:   a.ContentID,
+			})
+			continue
 		}
-		inlinedAttachments = append(inlinedAttachments, mailjetAttachment{
+		attachments = append(attachments, mailjetAttachment{
 			Name:        a.FileName,
 			Content:     a.Content,
 			ContentType: a.Type,
-			ContentID:   a.ContentID,
 		})
 	}
 
 	// build params
 	params := mapData{
-		"From":    m.from,
-		"Subject": m.subject,
+		"From": newMailjetAddress(msg.From),
 	}
 
-	if len(m.toList) > 0 {
-		params["To"] = m.toList
+	// a template brings its own subject unless one is given
+	if msg.Template == "" || msg.Subject != "" {
+		params["Subject"] = msg.Subject
+	}
+	if msg.Template != "" {
+		// the id is verified to be numeric
+		id, _ := strconv.Atoi(msg.Template)
+		params["TemplateID"] = id
+		params["TemplateLanguage"] = true
+		if len(msg.TemplateData) > 0 {
+			params["Variables"] = msg.TemplateData
+		}
 	}
 
-	if len(m.ccList) > 0 {
-		params["Cc"] = m.ccList
+	if len(msg.To) > 0 {
+		params["To"] = mailjetAddresses(msg.To)
 	}
 
-	if len(m.bccList) > 0 {
-		params["Bcc"] = m.bccList
+	if len(msg.Cc) > 0 {
+		params["Cc"] = mailjetAddresses(msg.Cc)
 	}
 
-	if m.replyTo.Email != "" {
-		params["ReplyTo"] = m.replyTo
+	if len(msg.Bcc) > 0 {
+		params["Bcc"] = mailjetAddresses(msg.Bcc)
 	}
-	if len(m.bodyText) > 0 {
-		params["TextPart"] = m.bodyText
+
+	if msg.ReplyTo.Email != "" {
+		params["ReplyTo"] = newMailjetAddress(msg.ReplyTo)
+	}
+	if len(msg.Text) > 0 {
+		params["TextPart"] = msg.Text
 	}
 
-	if len(m.bodyHTML) > 0 {
-		params["HTMLPart"] = m.bodyHTML
+	if len(msg.HTML) > 0 {
+		params["HTMLPart"] = msg.HTML
 	}
 
-	if len(m.attachmentFiles) > 0 {
+	if len(attachments) > 0 {
 		params["Attachments"] = attachments
 	}
 
-	if len(m.attachmentInlineFiles) > 0 {
+	if len(inlinedAttachments) > 0 {
 		params["InlinedAttachments"] = inlinedAttachments
 	}
 
+	return params
+}
+
+// newMailjetAddress return an address with the capitalized keys of mailjet
+func newMailjetAddress(a Address) mailjetAddress {
+	return mailjetAddress{Name: a.Name, Email: a.Email}
+}
+
+// mailjetAddresses return the addresses with the capitalized keys of mailjet
+func mailjetAddresses(list []Address) []mailjetAddress {
+	addresses := []mailjetAddress{}
+	for _, a := range list {
+		addresses = append(addresses, newMailjetAddress(a))
+	}
+	return addresses
+}
+
+// batchSize return the max messages per request
+func (mailjet) batchSize() int {
+	return mailjetMaxReceipents
+}
+
+// maxBatchPayload return the max size in bytes of a send request, every
+// message of the batch carries the attachments
+func (mailjet) maxBatchPayload() int64 {
+	return mailjetMaxBatchPayload
+}
+
+// sendBatch send one message per receipent in a single request, mailjet
+// reports the status of every message so a receipent may fail alone
+func (m *mailjet) sendBatch(ctx context.Context, msg *Message, recipients []Recipient) ([]RecipientResult, error) {
+	messages := []mapData{}
+	for _, r := range recipients {
+		messages = append(messages, m.params(msg.personalize(r)))
+	}
 	body := struct {
 		Messages []mapData `json:"Messages"`
-	}{[]mapData{params}}
-	return m.processMailjetRequest(body)
+	}{messages}
+
+	// wait for the client side rate limiter if any
+	ctx, err := m.c.limiter.wait(ctx, "mailjet", len(recipients))
+	if err != nil {
+		return nil, err
+	}
+
+	status, bodyByte, err := m.post(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// the messages of the response are in the order of the request
+	result := struct {
+		Messages []struct {
+			Status string             `json:"Status"`
+			To     []mailjetReceipent `json:"To"`
+			Errors []mailjetError     `json:"Errors"`
+		} `json:"Messages"`
+	}{}
+	_ = json.Unmarshal(bodyByte, &result)
+	if len(result.Messages) != len(recipients) {
+		return nil, m.providerError(status, bodyByte)
+	}
+	results := []RecipientResult{}
+	for i, r := range recipients {
+		res := RecipientResult{Email: r.Address.Email}
+		msgResult := result.Messages[i]
+		switch {
+		case msgResult.Status == "success" && len(msgResult.To) > 0:
+			res.MessageID = msgResult.To[0].MessageUUID
+		case len(msgResult.Errors) > 0:
+			e := msgResult.Errors[0]
+			res.Err = newProviderError("mailjet", status, bodyByte, e.ErrorCode, e.ErrorMessage)
+		default:
+			res.Err = m.providerError(status, bodyByte)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// maxAttachmentSize return the max total size in bytes of the attachments
+func (mailjet) maxAttachmentSize() int64 {
+	return mailjetMaxFileSize
+}
+
+// maxReceipents return the max to, cc and bcc receipents per email
+func (mailjet) maxReceipents() int {
+	return mailjetMaxReceipents
 }
 
 // verifyParams verify the required params
-func (m mailjet) verifyParams() {
+func (m mailjet) verifyParams(msg *Message) error {
+	v := validation{service: "mailjet"}
 	if m.configs.PrivateKey == "" ||
 		m.configs.PublicKey == "" {
-		panic("gomailer: for mailjetapp you must provide PrivateKey and PublicKey in config")
-	}
-	if m.from.Email == "" {
-		panic("gomailer: you must provide from")
+		v.add(ErrMissingCredentials, "for mailjetapp you must provide PrivateKey and PublicKey in config")
 	}
-	if len(m.toList) <= 0 {
-		panic("gomailer: you must provide at least one receipent")
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), mailjetMaxReceipents)
+	v.verifyBody(msg)
+	v.verifyAttachments(msg.attachmentSize(), mailjetMaxFileSize)
+	if _, err := strconv.Atoi(msg.Template); msg.Template != "" && err != nil {
+		v.add(ErrInvalidTemplate, "mailjet template id must be numeric, got %q", msg.Template)
 	}
-	if len(m.toList)+len(m.ccList)+len(m.bccList) > mailjetMaxReceipents {
-		panic(fmt.Sprintf("mailer: total number of receipents including to/cc/bcc can not be greater than %d for mailjet", mailjetMaxReceipents))
+	return v.err()
+}
+
+// processMailjetRequest perform a post request with content type application/json for mailjet
+func (m *mailjet) processMailjetRequest(ctx context.Context, bodyParams interface{}) (*SendResult, error) {
+	status, bodyByte, err := m.post(ctx, bodyParams)
+	if err != nil {
+		return nil, err
 	}
-	if m.bodyText == "" && m.bodyHTML == "" {
-		panic("gomailer: you must provide a Text or HTML body")
+	if status != http.StatusOK {
+		return nil, m.providerError(status, bodyByte)
 	}
+	return m.sendResult(status, bodyByte), nil
 }
 
-// processMailjetRequest perform a post request with content type application/json for mailjet
-func (m *mailjet) processMailjetRequest(bodyParams interface{}) error {
+// post perform the request and return the status and body of the response
+func (m *mailjet) post(ctx context.Context, bodyParams interface{}) (int, []byte, error) {
 	body, err := toJSON(bodyParams)
 	if err != nil {
-		return err
+		return 0, nil, newLocalError("mailjet", err)
 	}
 
-	req, errReq := http.NewRequest("POST", m.messageURL(), bytes.NewBuffer(body))
+	req, errReq := http.NewRequestWithContext(ctx, "POST", m.messageURL(), bytes.NewBuffer(body))
 	if errReq != nil {
-		return errReq
+		return 0, nil, newLocalError("mailjet", errReq)
 	}
 
 	req.SetBasicAuth(m.configs.PublicKey, m.configs.PrivateKey)
@@ -266,13 +295,64 @@ func (m *mailjet) processMailjetRequest(bodyParams interface{}) error {
 
 	resp, err := m.c.getDefaultClient().Do(req)
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return errors.New(string(body))
+	defer resp.Body.Close()
+
+	bodyByte, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, bodyByte, nil
+}
+
+// sendResult decode a mailjet success response, every receipent gets its own message id
+func (mailjet) sendResult(status int, body []byte) *SendResult {
+	result := struct {
+		Messages []struct {
+			To  []mailjetReceipent `json:"To"`
+			Cc  []mailjetReceipent `json:"Cc"`
+			Bcc []mailjetReceipent `json:"Bcc"`
+		} `json:"Messages"`
+	}{}
+	_ = json.Unmarshal(body, &result)
+	r := &SendResult{Service: "mailjet", StatusCode: status, Raw: body}
+	for _, m := range result.Messages {
+		for _, list := range [][]mailjetReceipent{m.To, m.Cc, m.Bcc} {
+			for _, a := range list {
+				r.Accepted = append(r.Accepted, a.Email)
+				if a.MessageUUID != "" {
+					r.MessageIDs = append(r.MessageIDs, a.MessageUUID)
+				}
+			}
+		}
+	}
+	return r
+}
+
+// providerError decode a mailjet error response, errors are either global
+// or reported per message
+func (mailjet) providerError(status int, body []byte) error {
+	result := struct {
+		mailjetError
+		Messages []struct {
+			Errors []mailjetError `json:"Errors"`
+		} `json:"Messages"`
+	}{}
+	_ = json.Unmarshal(body, &result)
+	code, msgs := result.ErrorCode, []string{}
+	if result.ErrorMessage != "" {
+		msgs = append(msgs, result.ErrorMessage)
+	}
+	for _, m := range result.Messages {
+		for _, e := range m.Errors {
+			if code == "" {
+				code = e.ErrorCode
+			}
+			msgs = append(msgs, e.ErrorMessage)
+		}
 	}
-	return nil
+	return newProviderError("mailjet", status, body, code, strings.Join(msgs, "; "))
 }
 
 // {
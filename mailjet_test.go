@@ -0,0 +1,54 @@
+package gomailer
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMailjetAddressKeys(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Write([]byte(`{"Messages":[{"Status":"success","To":[{"Email":"t@x.com","MessageUUID":"1"}]}]}`))
+	}))
+	defer srv.Close()
+
+	s, _ := NewSender(MAILJET, Configs{PublicKey: "pub", PrivateKey: "priv", BaseURL: srv.URL})
+	_, err := s.Send(context.Background(), &Message{
+		From:    Address{Name: "F", Email: "f@x.com"},
+		To:      []Address{{Name: "T", Email: "t@x.com"}},
+		Cc:      []Address{{Email: "c@x.com"}},
+		Bcc:     []Address{{Email: "b@x.com"}},
+		ReplyTo: Address{Email: "r@x.com"},
+		Subject: "subject",
+		Text:    "text",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// map keys are case sensitive, unlike decoding into a struct
+	payload := struct {
+		Messages []map[string]json.RawMessage
+	}{}
+	if err := json.Unmarshal(body, &payload); err != nil || len(payload.Messages) != 1 {
+		t.Fatalf("bad payload %s: %v", body, err)
+	}
+	msg := payload.Messages[0]
+	want := map[string]string{
+		"From":    `{"Name":"F","Email":"f@x.com"}`,
+		"To":      `[{"Name":"T","Email":"t@x.com"}]`,
+		"Cc":      `[{"Email":"c@x.com"}]`,
+		"Bcc":     `[{"Email":"b@x.com"}]`,
+		"ReplyTo": `{"Email":"r@x.com"}`,
+	}
+	for k, v := range want {
+		if got := string(msg[k]); got != v {
+			t.Errorf("%s: got %s, want %s", k, got, v)
+		}
+	}
+}
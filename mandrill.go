@@ -1,3 +1,258 @@
 package gomailer
 
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
 // https://mandrillapp.com/api/docs/messages.html
+
+const (
+	// mandrillBaseURL describes mandrill mail sending api base url
+	mandrillBaseURL = "https://mandrillapp.com/api/1.0"
+	// mandrillMaxFileSize describes the max file size in bytes for sending per email for mandrill
+	mandrillMaxFileSize int64 = 25 * 1000000
+	// mandrillMaxReceipents describes the max receipents per email
+	mandrillMaxReceipents = 1000
+)
+
+type (
+	// mandrill describes a mandrill type
+	mandrill struct {
+		c       client
+		configs Configs
+	}
+
+	// mandrillAttachment describes an email attachment or inline image
+	mandrillAttachment struct {
+		Type    string `json:"type"`
+		Name    string `json:"name"`
+		Content string `json:"content"`
+	}
+
+	// mandrillAddress describes a receipent, the type is one of to, cc or bcc
+	mandrillAddress struct {
+		Email string `json:"email"`
+		Name  string `json:"name"`
+		Type  string `json:"type"`
+	}
+
+	// mandrillVar describes a merge var or an editable region of a template
+	mandrillVar struct {
+		Name    string      `json:"name"`
+		Content interface{} `json:"content"`
+	}
+
+	// mandrillStatus describes the per receipent sending status
+	mandrillStatus struct {
+		Email        string `json:"email"`
+		Status       string `json:"status"`
+		RejectReason string `json:"reject_reason"`
+		ID           string `json:"_id"`
+	}
+)
+
+// messageURL return a message url, templates are sent to their own endpoint
+func (m *mandrill) messageURL(template bool) string {
+	url := mandrillBaseURL
+	if m.configs.BaseURL != "" {
+		url = m.configs.BaseURL
+	}
+	if template {
+		return fmt.Sprintf("%s/messages/send-template.json", url)
+	}
+	return fmt.Sprintf("%s/messages/send.json", url)
+}
+
+// Send process an email sending and return the provider response
+func (m *mandrill) Send(ctx context.Context, msg *Message) (*SendResult, error) {
+	// verify params for sending email
+	if err := m.verifyParams(msg); err != nil {
+		return nil, err
+	}
+
+	// build attachment, inline images are referenced by name as cid:name
+	attachments := []mandrillAttachment{}
+	images := []mandrillAttachment{}
+	for _, f := range msg.Attachments {
+		a := f.encode()
+		if f.Inline {
+			images = append(images, mandrillAttachment{
+				Type:    a.Type,
+				Name:    a.ContentID,
+				Content: a.Content,
+			})
+			continue
+		}
+		attachments = append(attachments, mandrillAttachment{
+			Type:    a.Type,
+			Name:    a.FileName,
+			Content: a.Content,
+		})
+	}
+
+	// mandrill takes to/cc/bcc in a single list distinguished by type
+	to := []mandrillAddress{}
+	for _, list := range []struct {
+		kind      string
+		addresses []Address
+	}{{"to", msg.To}, {"cc", msg.Cc}, {"bcc", msg.Bcc}} {
+		for _, a := range list.addresses {
+			to = append(to, mandrillAddress{Email: a.Email, Name: a.Name, Type: list.kind})
+		}
+	}
+
+	message := mapData{
+		"from_email": msg.From.Email,
+		"from_name":  msg.From.Name,
+		"to":         to,
+		// without preserving, every receipent would only see themselves in To/Cc
+		"preserve_recipients": true,
+	}
+
+	// a template brings its own subject unless one is given
+	if msg.Template == "" || msg.Subject != "" {
+		message["subject"] = msg.Subject
+	}
+
+	if msg.ReplyTo.Email != "" {
+		message["headers"] = map[string]string{
+			"Reply-To": msg.ReplyTo.format(),
+		}
+	}
+
+	if len(msg.Text) > 0 {
+		message["text"] = msg.Text
+	}
+
+	if len(msg.HTML) > 0 {
+		message["html"] = msg.HTML
+	}
+
+	if len(attachments) > 0 {
+		message["attachments"] = attachments
+	}
+
+	if len(images) > 0 {
+		message["images"] = images
+	}
+
+	params := mapData{
+		"key":     m.configs.APIKey,
+		"message": message,
+	}
+
+	// a template is rendered with the data as handlebars merge vars, the
+	// editable regions are left as designed
+	if msg.Template != "" {
+		params["template_name"] = msg.Template
+		params["template_content"] = []mandrillVar{}
+		vars := []mandrillVar{}
+		for _, k := range dataKeys(msg.TemplateData) {
+			vars = append(vars, mandrillVar{Name: k, Content: msg.TemplateData[k]})
+		}
+		message["global_merge_vars"] = vars
+		message["merge_language"] = "handlebars"
+	}
+
+	// wait for the client side rate limiter if any
+	ctx, err := m.c.limiter.wait(ctx, "mandrill", msg.receipents())
+	if err != nil {
+		return nil, err
+	}
+
+	return m.processMandrillRequest(ctx, m.messageURL(msg.Template != ""), params)
+}
+
+// maxAttachmentSize return the max total size in bytes of the attachments
+func (mandrill) maxAttachmentSize() int64 {
+	return mandrillMaxFileSize
+}
+
+// maxReceipents return the max to, cc and bcc receipents per email
+func (mandrill) maxReceipents() int {
+	return mandrillMaxReceipents
+}
+
+// verifyParams verify the required params
+func (m mandrill) verifyParams(msg *Message) error {
+	v := validation{service: "mandrill"}
+	if m.configs.APIKey == "" {
+		v.add(ErrMissingCredentials, "for mandrill you must provide APIKey in config")
+	}
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), mandrillMaxReceipents)
+	v.verifyBody(msg)
+	v.verifyAttachments(msg.attachmentSize(), mandrillMaxFileSize)
+	return v.err()
+}
+
+// processMandrillRequest perform a post request with content type application/json for mandrill
+func (m *mandrill) processMandrillRequest(ctx context.Context, url string, bodyParams map[string]interface{}) (*SendResult, error) {
+	body, err := toJSON(bodyParams)
+	if err != nil {
+		return nil, newLocalError("mandrill", err)
+	}
+	req, errReq := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if errReq != nil {
+		return nil, newLocalError("mandrill", errReq)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := m.c.getDefaultClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyByte, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, m.providerError(resp.StatusCode, bodyByte)
+	}
+
+	// mandrill responds with 200 even if some receipents are rejected
+	statuses := []mandrillStatus{}
+	if err := json.Unmarshal(bodyByte, &statuses); err != nil {
+		return nil, err
+	}
+	r := &SendResult{Service: "mandrill", StatusCode: resp.StatusCode, Raw: bodyByte}
+	rejected := []string{}
+	for _, s := range statuses {
+		if s.ID != "" {
+			r.MessageIDs = append(r.MessageIDs, s.ID)
+		}
+		if s.Status != "rejected" && s.Status != "invalid" {
+			r.Accepted = append(r.Accepted, s.Email)
+			continue
+		}
+		reason := s.RejectReason
+		if reason == "" {
+			reason = s.Status
+		}
+		r.Rejected = append(r.Rejected, Rejection{Email: s.Email, Reason: reason})
+		rejected = append(rejected, fmt.Sprintf("%s (%s)", s.Email, reason))
+	}
+	if len(rejected) > 0 {
+		return r, newProviderError("mandrill", resp.StatusCode, bodyByte, "rejected", "rejected receipents: "+strings.Join(rejected, ", "))
+	}
+	return r, nil
+}
+
+// providerError decode a mandrill error response
+func (mandrill) providerError(status int, body []byte) error {
+	result := struct {
+		Name    string `json:"name"`
+		Message string `json:"message"`
+	}{}
+	_ = json.Unmarshal(body, &result)
+	return newProviderError("mandrill", status, body, result.Name, result.Message)
+}
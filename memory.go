@@ -0,0 +1,91 @@
+package gomailer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// DefaultOutbox is the outbox of the memory driver when Configs.Outbox is not set
+var DefaultOutbox = &Outbox{}
+
+type (
+	// memory describes a memory type, it keeps the messages in an outbox
+	// instead of sending them, meant for development and tests
+	memory struct {
+		c       client
+		configs Configs
+	}
+
+	// Outbox describes the messages sent by the memory driver, it is safe
+	// for concurrent use
+	Outbox struct {
+		mu       sync.Mutex
+		messages []*Message
+	}
+)
+
+// Send process an email sending and return the provider response
+func (m *memory) Send(ctx context.Context, msg *Message) (*SendResult, error) {
+	// verify params for sending email
+	if err := m.verifyParams(msg); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.outbox().add(msg.clone())
+	r := newSendResult("memory", 0, nil, msg.To, msg.Cc, msg.Bcc)
+	r.MessageIDs = []string{uuid.New().String()}
+	return r, nil
+}
+
+// outbox return the configured outbox or the default one
+func (m *memory) outbox() *Outbox {
+	if m.configs.Outbox != nil {
+		return m.configs.Outbox
+	}
+	return DefaultOutbox
+}
+
+// verifyParams verify the required params
+func (m memory) verifyParams(msg *Message) error {
+	v := validation{service: "memory"}
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), 0)
+	v.verifyBody(msg)
+	return v.err()
+}
+
+// add append a message to the outbox
+func (o *Outbox) add(msg *Message) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = append(o.messages, msg)
+}
+
+// Messages return the sent messages in order
+func (o *Outbox) Messages() []*Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]*Message(nil), o.messages...)
+}
+
+// Last return the last sent message, nil if the outbox is empty
+func (o *Outbox) Last() *Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.messages) <= 0 {
+		return nil
+	}
+	return o.messages[len(o.messages)-1]
+}
+
+// Reset remove every message from the outbox
+func (o *Outbox) Reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = nil
+}
@@ -0,0 +1,146 @@
+package gomailer
+
+import (
+	"bytes"
+	"context"
+	b64 "encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"path/filepath"
+)
+
+type (
+	// Address describes an email address
+	Address struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+
+	// Attachment describes a file attached to a message, the content is kept in
+	// memory so a message can be sent more than once, stored or queued
+	Attachment struct {
+		FileName    string `json:"filename"`               // FileName represents the file name, also used as the Content-ID of inline files
+		ContentType string `json:"content_type,omitempty"` // ContentType represents the mime type, detected from the file name when empty
+		Content     []byte `json:"content"`                // Content represents the raw file content
+		Inline      bool   `json:"inline,omitempty"`       // Inline represents an inline file referenced from the html body as cid:FileName
+	}
+
+	// Message describes a provider neutral email, it can be built once and
+	// handed to any Sender
+	Message struct {
+		ID          string       `json:"id,omitempty"` // ID represents a stable identity of the message, providers which drop duplicates such as postageapp recognise a resend by it
+		From        Address      `json:"from"`
+		To          []Address    `json:"to"`
+		Cc          []Address    `json:"cc,omitempty"`
+		Bcc         []Address    `json:"bcc,omitempty"`
+		ReplyTo     Address      `json:"reply_to"`
+		Subject     string       `json:"subject"`
+		HTML        string       `json:"html,omitempty"`
+		Text        string       `json:"text,omitempty"`
+		Attachments []Attachment `json:"attachments,omitempty"`
+		Tags        []string     `json:"tags,omitempty"` // Tags represents the categories of the message, used for routing
+
+		Template     string                 `json:"template,omitempty"`      // Template represents the id or alias of a template hosted by the provider, which renders the bodies
+		TemplateData map[string]interface{} `json:"template_data,omitempty"` // TemplateData represents the variables of the hosted template
+	}
+
+	// Sender describes a driver which delivers a Message
+	Sender interface {
+		// Send process an email sending and return the provider message id(s) and receipent status
+		Send(ctx context.Context, m *Message) (*SendResult, error)
+	}
+)
+
+// NewAttachment return an attachment with the content of r
+func NewAttachment(fileName string, r io.Reader) (Attachment, error) {
+	b := &bytes.Buffer{}
+	if _, err := io.Copy(b, r); err != nil {
+		return Attachment{}, err
+	}
+	return Attachment{FileName: filepath.Base(fileName), Content: b.Bytes()}, nil
+}
+
+// NewAttachmentFile return an attachment with the content of the file on disk
+func NewAttachmentFile(path string) (Attachment, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+	return Attachment{FileName: filepath.Base(path), Content: b}, nil
+}
+
+// encode return the attachment encoded in base64 as most provider apis expect it
+func (a Attachment) encode() attachment {
+	mType := a.ContentType
+	if mType == "" {
+		//extract mime type from file
+		mType = mime.TypeByExtension(filepath.Ext(a.FileName))
+	}
+	disposition := "attachment"
+	if a.Inline {
+		disposition = "inline"
+	}
+	return attachment{
+		Content:     b64.StdEncoding.EncodeToString(a.Content),
+		Type:        mType,
+		FileName:    a.FileName,
+		ContentID:   a.FileName,
+		Disposition: disposition,
+		Size:        int64(len(a.Content)),
+	}
+}
+
+// attachmentSize return the total size in bytes of the attachments
+func (m *Message) attachmentSize() int64 {
+	var size int64
+	for _, a := range m.Attachments {
+		size += int64(len(a.Content))
+	}
+	return size
+}
+
+// payloadSize return an estimate of the size in bytes of the email in a json
+// api call, the attachments are sent in base64
+func (m *Message) payloadSize() int64 {
+	c := *m
+	c.Attachments = nil
+	b, _ := toJSON(c)
+	size := int64(len(b))
+	for _, a := range m.Attachments {
+		size += int64(b64.StdEncoding.EncodedLen(len(a.Content)) + len(a.FileName) + len(a.ContentType))
+	}
+	return size
+}
+
+// hasAttachments reports whether the message has regular or inline attachments
+func (m *Message) hasAttachments(inline bool) bool {
+	for _, a := range m.Attachments {
+		if a.Inline == inline {
+			return true
+		}
+	}
+	return false
+}
+
+// receipents return the number of to, cc and bcc receipents
+func (m *Message) receipents() int {
+	return len(m.To) + len(m.Cc) + len(m.Bcc)
+}
+
+// clone return a deep copy of the message so later changes of the source do not leak
+func (m *Message) clone() *Message {
+	c := *m
+	c.To = append([]Address(nil), m.To...)
+	c.Cc = append([]Address(nil), m.Cc...)
+	c.Bcc = append([]Address(nil), m.Bcc...)
+	c.Attachments = append([]Attachment(nil), m.Attachments...)
+	c.Tags = append([]string(nil), m.Tags...)
+	if m.TemplateData != nil {
+		c.TemplateData = map[string]interface{}{}
+		for k, v := range m.TemplateData {
+			c.TemplateData[k] = v
+		}
+	}
+	return &c
+}
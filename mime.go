@@ -0,0 +1,250 @@
+package gomailer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// mimeLineLength describes the max length of a base64 encoded line
+const mimeLineLength = 76
+
+// mimeMessage describes the state needed to build an RFC 5322 email
+type mimeMessage struct {
+	*Message
+	id  string // id represents the Message-ID, generated when empty
+	bcc bool   // bcc represents whether the Bcc header is written, only for stored copies
+}
+
+// mailAddress return an RFC 5322 formatted address, encoding the name when needed
+func (a Address) mailAddress() string {
+	return (&mail.Address{Name: a.Name, Address: a.Email}).String()
+}
+
+// mailAddresses return a comma separated RFC 5322 address list
+func mailAddresses(list []Address) string {
+	s := []string{}
+	for _, a := range list {
+		s = append(s, a.mailAddress())
+	}
+	return strings.Join(s, ", ")
+}
+
+// partCreator creates a mime part with the given header and return its body writer
+type partCreator func(h textproto.MIMEHeader) (io.Writer, error)
+
+// bytes build the full message, the multipart tree depends on the content:
+// multipart/mixed wraps attachments, multipart/related wraps inline files
+// and multipart/alternative holds the text and html bodies
+func (m mimeMessage) bytes() ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	// write headers
+	h := textproto.MIMEHeader{}
+	h.Set("From", m.From.mailAddress())
+	h.Set("To", mailAddresses(m.To))
+	if len(m.Cc) > 0 {
+		h.Set("Cc", mailAddresses(m.Cc))
+	}
+	if m.bcc && len(m.Bcc) > 0 {
+		h.Set("Bcc", mailAddresses(m.Bcc))
+	}
+	if m.ReplyTo.Email != "" {
+		h.Set("Reply-To", m.ReplyTo.mailAddress())
+	}
+	h.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	h.Set("Date", time.Now().Format(time.RFC1123Z))
+	id := m.id
+	if id == "" {
+		id = m.messageID()
+	}
+	h.Set("Message-ID", id)
+	h.Set("MIME-Version", "1.0")
+
+	// the top level part shares the message headers
+	create := func(ph textproto.MIMEHeader) (io.Writer, error) {
+		for k, v := range ph {
+			h[k] = v
+		}
+		writeMIMEHeader(buf, h)
+		return buf, nil
+	}
+
+	if err := m.writeMixed(create); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// messageID generate a unique Message-ID using the sender domain
+func (m mimeMessage) messageID() string {
+	domain := "localhost"
+	if i := strings.LastIndex(m.From.Email, "@"); i >= 0 {
+		domain = m.From.Email[i+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", uuid.New().String(), domain)
+}
+
+// writeMixed write the body and inline files followed by the attachments
+func (m mimeMessage) writeMixed(create partCreator) error {
+	var inline, regular []attachment
+	for _, a := range m.Attachments {
+		if a.Inline {
+			inline = append(inline, a.encode())
+		} else {
+			regular = append(regular, a.encode())
+		}
+	}
+	if len(regular) <= 0 {
+		return m.writeRelated(create, inline)
+	}
+	w, err := createMultipart(create, "mixed")
+	if err != nil {
+		return err
+	}
+	if err := m.writeRelated(w.CreatePart, inline); err != nil {
+		return err
+	}
+	for _, a := range regular {
+		if err := writeMIMEAttachment(w.CreatePart, a); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// writeRelated write the body followed by the inline files
+func (m mimeMessage) writeRelated(create partCreator, inline []attachment) error {
+	if len(inline) <= 0 {
+		return m.writeBody(create)
+	}
+	w, err := createMultipart(create, "related")
+	if err != nil {
+		return err
+	}
+	if err := m.writeBody(w.CreatePart); err != nil {
+		return err
+	}
+	for _, a := range inline {
+		if err := writeMIMEAttachment(w.CreatePart, a); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// writeBody write the text and html bodies, plain text goes first
+func (m mimeMessage) writeBody(create partCreator) error {
+	if m.Text == "" || m.HTML == "" {
+		if m.HTML != "" {
+			return writeMIMEText(create, "text/html", m.HTML)
+		}
+		return writeMIMEText(create, "text/plain", m.Text)
+	}
+	w, err := createMultipart(create, "alternative")
+	if err != nil {
+		return err
+	}
+	if err := writeMIMEText(w.CreatePart, "text/plain", m.Text); err != nil {
+		return err
+	}
+	if err := writeMIMEText(w.CreatePart, "text/html", m.HTML); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// createMultipart create a multipart part of the given subtype and return its writer
+func createMultipart(create partCreator, subtype string) (*multipart.Writer, error) {
+	// the boundary is needed in the part header before the writer exists
+	boundary := multipart.NewWriter(nil).Boundary()
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", fmt.Sprintf("multipart/%s; boundary=%s", subtype, boundary))
+	part, err := create(h)
+	if err != nil {
+		return nil, err
+	}
+	w := multipart.NewWriter(part)
+	if err := w.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// writeMIMEText write a quoted-printable text part
+func writeMIMEText(create partCreator, contentType, body string) error {
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", contentType+"; charset=utf-8")
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	part, err := create(h)
+	if err != nil {
+		return err
+	}
+	return writeQuotedPrintable(part, body)
+}
+
+// writeMIMEAttachment write a base64 encoded file part
+func writeMIMEAttachment(create partCreator, a attachment) error {
+	// the detected type may already carry params such as charset
+	contentType, params, err := mime.ParseMediaType(a.Type)
+	if err != nil {
+		contentType, params = "application/octet-stream", map[string]string{}
+	}
+	params["name"] = a.FileName
+	disposition := a.Disposition
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", mime.FormatMediaType(contentType, params))
+	h.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.FileName}))
+	h.Set("Content-Transfer-Encoding", "base64")
+	if disposition == "inline" {
+		h.Set("Content-ID", fmt.Sprintf("<%s>", a.ContentID))
+	}
+	part, err := create(h)
+	if err != nil {
+		return err
+	}
+	// the attachment content is already base64, only wrap the lines
+	for i := 0; i < len(a.Content); i += mimeLineLength {
+		end := i + mimeLineLength
+		if end > len(a.Content) {
+			end = len(a.Content)
+		}
+		if _, err := io.WriteString(part, a.Content[i:end]+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeQuotedPrintable write body in quoted-printable encoding
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeMIMEHeader write the top level headers of a message
+func writeMIMEHeader(w io.Writer, h textproto.MIMEHeader) {
+	// keep a stable order so messages are easy to read and diff
+	order := []string{"From", "To", "Cc", "Bcc", "Reply-To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type", "Content-Transfer-Encoding"}
+	for _, k := range order {
+		if v := h.Get(k); v != "" {
+			fmt.Fprintf(w, "%s: %s\r\n", k, v)
+		}
+	}
+	fmt.Fprint(w, "\r\n")
+}
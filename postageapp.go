@@ -1,3 +1,219 @@
 package gomailer
 
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
 // http://help.postageapp.com/kb
+// https://dev.postageapp.com/api
+
+const (
+	// postageappBaseURL describes postageapp mail sending api base url
+	postageappBaseURL = "https://api.postageapp.com/v.1.0"
+	// postageappMaxFileSize describes the max file size in bytes for sending per email for postageapp
+	postageappMaxFileSize int64 = 10 * 1000000
+	// postageappMaxReceipents describes the max receipents per email
+	postageappMaxReceipents = 1000
+)
+
+type (
+	// postageapp describes a postageapp type
+	postageapp struct {
+		c       client
+		configs Configs
+	}
+
+	// postageappAttachment describes an email attachment
+	postageappAttachment struct {
+		ContentType string `json:"content_type"`
+		Content     string `json:"content"`
+	}
+
+	// postageappResponse describes the postageapp response envelope
+	postageappResponse struct {
+		Response struct {
+			Status  string `json:"status"`
+			UID     string `json:"uid"`
+			Message string `json:"message"`
+		} `json:"response"`
+	}
+)
+
+// messageURL return a message url
+func (p *postageapp) messageURL() string {
+	url := postageappBaseURL
+	if p.configs.BaseURL != "" {
+		url = p.configs.BaseURL
+	}
+	return fmt.Sprintf("%s/send_message.json", url)
+}
+
+// Send process an email sending and return the provider response
+func (p *postageapp) Send(ctx context.Context, msg *Message) (*SendResult, error) {
+	// verify params for sending email
+	if err := p.verifyParams(msg); err != nil {
+		return nil, err
+	}
+
+	// build attachment, postageapp keys attachments by file name and has no
+	// notion of content id so inline files are referenced as cid:<file name>
+	attachments := map[string]postageappAttachment{}
+	for _, f := range msg.Attachments {
+		a := f.encode()
+		attachments[a.FileName] = postageappAttachment{
+			ContentType: a.Type,
+			Content:     a.Content,
+		}
+	}
+
+	// postageapp delivers a separate copy to every receipent, cc receipents
+	// are listed in the header so the to receipents can see them
+	recipients := []string{}
+	for _, list := range [][]Address{msg.To, msg.Cc, msg.Bcc} {
+		for _, a := range list {
+			recipients = append(recipients, a.format())
+		}
+	}
+
+	headers := map[string]string{
+		"from": msg.From.format(),
+	}
+	// a template brings its own subject unless one is given
+	if msg.Template == "" || msg.Subject != "" {
+		headers["subject"] = msg.Subject
+	}
+	if len(msg.Cc) > 0 {
+		var cList []string
+		for _, a := range msg.Cc {
+			cList = append(cList, a.format())
+		}
+		headers["cc"] = strings.Join(cList, ",")
+	}
+	if msg.ReplyTo.Email != "" {
+		headers["reply-to"] = msg.ReplyTo.format()
+	}
+
+	content := map[string]string{}
+	if len(msg.Text) > 0 {
+		content["text/plain"] = msg.Text
+	}
+	if len(msg.HTML) > 0 {
+		content["text/html"] = msg.HTML
+	}
+
+	arguments := mapData{
+		"recipients": recipients,
+		"headers":    headers,
+	}
+	if len(content) > 0 {
+		arguments["content"] = content
+	}
+
+	// the template is referenced by its slug, variables fill its placeholders
+	if msg.Template != "" {
+		arguments["template"] = msg.Template
+		if len(msg.TemplateData) > 0 {
+			arguments["variables"] = msg.TemplateData
+		}
+	}
+	if len(attachments) > 0 {
+		arguments["attachments"] = attachments
+	}
+
+	params := mapData{
+		"api_key": p.configs.APIKey,
+		// uid makes the request idempotent, postageapp ignores a message with a known uid
+		"uid":       p.uid(msg),
+		"arguments": arguments,
+	}
+
+	// wait for the client side rate limiter if any
+	ctx, err := p.c.limiter.wait(ctx, "postageapp", msg.receipents())
+	if err != nil {
+		return nil, err
+	}
+
+	return p.processPostageappRequest(ctx, msg, params)
+}
+
+// maxAttachmentSize return the max total size in bytes of the attachments
+func (postageapp) maxAttachmentSize() int64 {
+	return postageappMaxFileSize
+}
+
+// maxReceipents return the max to, cc and bcc receipents per email
+func (postageapp) maxReceipents() int {
+	return postageappMaxReceipents
+}
+
+// uid return the uid of a message, the message id when set so a resend is
+// dropped as a duplicate, a fresh one otherwise
+func (postageapp) uid(msg *Message) string {
+	if msg.ID != "" {
+		return msg.ID
+	}
+	return uuid.New().String()
+}
+
+// verifyParams verify the required params
+func (p postageapp) verifyParams(msg *Message) error {
+	v := validation{service: "postageapp"}
+	if p.configs.APIKey == "" {
+		v.add(ErrMissingCredentials, "for postageapp you must provide the project APIKey in config")
+	}
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), postageappMaxReceipents)
+	v.verifyBody(msg)
+	v.verifyAttachments(msg.attachmentSize(), postageappMaxFileSize)
+	return v.err()
+}
+
+// processPostageappRequest perform a post request with content type application/json for postageapp
+func (p *postageapp) processPostageappRequest(ctx context.Context, msg *Message, bodyParams map[string]interface{}) (*SendResult, error) {
+	body, err := toJSON(bodyParams)
+	if err != nil {
+		return nil, newLocalError("postageapp", err)
+	}
+	req, errReq := http.NewRequestWithContext(ctx, "POST", p.messageURL(), bytes.NewBuffer(body))
+	if errReq != nil {
+		return nil, newLocalError("postageapp", errReq)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := p.c.getDefaultClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyByte, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// postageapp reports the result in response.status rather than the http status
+	result := postageappResponse{}
+	if err := json.Unmarshal(bodyByte, &result); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, newProviderError("postageapp", resp.StatusCode, bodyByte, "", "")
+		}
+		return nil, err
+	}
+	if result.Response.Status != "ok" {
+		return nil, newProviderError("postageapp", resp.StatusCode, bodyByte, result.Response.Status, result.Response.Message)
+	}
+	r := newSendResult("postageapp", resp.StatusCode, bodyByte, msg.To, msg.Cc, msg.Bcc)
+	if result.Response.UID != "" {
+		r.MessageIDs = []string{result.Response.UID}
+	}
+	return r, nil
+}
@@ -0,0 +1,50 @@
+package gomailer
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPostageappUID(t *testing.T) {
+	uids := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		payload := struct {
+			UID string `json:"uid"`
+		}{}
+		_ = json.Unmarshal(b, &payload)
+		uids = append(uids, payload.UID)
+		w.Write([]byte(`{"response":{"status":"ok","uid":"` + payload.UID + `"},"data":{"message":{"id":1}}}`))
+	}))
+	defer srv.Close()
+
+	s, _ := NewSender(POSTAGEAPP, Configs{APIKey: "key", BaseURL: srv.URL})
+	msg := &Message{
+		From:    Address{Email: "john@example.com"},
+		To:      []Address{{Email: "jane@example.com"}},
+		Subject: "subject",
+		Text:    "text",
+	}
+	send := func() {
+		if _, err := s.Send(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send()
+	send()
+	if uids[0] == uids[1] {
+		t.Error("messages without an id share a uid")
+	}
+
+	msg.ID = "order-1042"
+	send()
+	send()
+	if uids[2] != "order-1042" || uids[3] != "order-1042" {
+		t.Errorf("got uids %v, want the message id on every resend", uids[2:])
+	}
+}
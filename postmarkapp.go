@@ -2,13 +2,12 @@ package gomailer
 
 import (
 	"bytes"
-	"errors"
+	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 )
 
@@ -21,24 +20,17 @@ const (
 	postmarkMaxFileSize int64 = 5 * 1000000
 	// postmarkMaxReceipents describes the max receipents per email
 	postmarkMaxReceipents = 50
+	// postmarkMaxBatch describes the max emails per batch request
+	postmarkMaxBatch = 500
+	// postmarkMaxBatchPayload describes the max size in bytes of a batch request
+	postmarkMaxBatchPayload int64 = 50 * 1000000
 )
 
 type (
 	// postmark describes a postmark type
 	postmark struct {
-		c                     client
-		configs               Configs
-		from                  address
-		toList                []address
-		ccList                []address
-		bccList               []address
-		replyTo               address
-		subject               string
-		bodyHTML              string
-		bodyText              string
-		attachmentFiles       []string
-		attachmentInlineFiles []string
-		attachments           []attachment
+		c       client
+		configs Configs
 	}
 
 	// attachment describes an email attachment
@@ -59,165 +51,97 @@ func (p *postmark) messageURL() string {
 	return fmt.Sprintf("%s/email", url)
 }
 
-// From sets an email sender address
-func (p *postmark) From(name, from string) Mailer {
-	p.from = address{Name: name, Email: from}
-	return p
+// templateURL return the url sending with a template
+func (p *postmark) templateURL() string {
+	return p.messageURL() + "/withTemplate"
 }
 
-// To sets receipents of an email
-func (p *postmark) To(name, to string) Mailer {
-	p.toList = append(p.toList, address{Name: name, Email: to})
-	return p
-}
-
-// Cc sets Cc receipents of an email
-func (p *postmark) Cc(name, to string) Mailer {
-	p.ccList = append(p.ccList, address{Name: name, Email: to})
-	return p
-}
-
-// Bcc sets Bcc receipents of an email
-func (p *postmark) Bcc(name, to string) Mailer {
-	p.bccList = append(p.bccList, address{Name: name, Email: to})
-	return p
-}
-
-// ReplyTo sets the reply-to address of an email
-func (p *postmark) ReplyTo(name, email string) Mailer {
-	p.replyTo = address{Name: name, Email: email}
-	return p
-}
-
-// Subject sets subject of an email
-func (p *postmark) Subject(subject string) Mailer {
-	p.subject = subject
-	return p
-}
-
-// BodyHTML sets html body for an email
-func (p *postmark) BodyHTML(body string) Mailer {
-	p.bodyHTML = body
-	return p
-}
-
-// BodyText sets plain text email body for an email
-func (p *postmark) BodyText(body string) Mailer {
-	p.bodyText = body
-	return p
-}
-
-// AttachmentFile set email attachments
-func (p *postmark) AttachmentFile(file string) Mailer {
-	p.attachmentFiles = append(p.attachmentFiles, file)
-	return p
-}
-
-// AttachmentInlineFile set email inline attachment
-func (p *postmark) AttachmentInlineFile(file string) Mailer {
-	p.attachmentInlineFiles = append(p.attachmentInlineFiles, file)
-	return p
-}
-
-// AttachmentReader set email attachments
-func (p *postmark) AttachmentReader(file string, r io.Reader) Mailer {
-	log.Println("gomailer: not implemented")
-	return p
-}
-
-// AttachmentInlineReader set email inline attachment
-func (p *postmark) AttachmentInlineReader(file string, r io.Reader) Mailer {
-	log.Println("gomailer: not implemented")
-	return p
+// batchURL return the url sending a batch, with or without templates
+func (p *postmark) batchURL(template bool) string {
+	if template {
+		return p.messageURL() + "/batchWithTemplates"
+	}
+	return p.messageURL() + "/batch"
 }
 
-// Send process an email sending
-func (p *postmark) Send() error {
+// Send process an email sending and return the provider response
+func (p *postmark) Send(ctx context.Context, msg *Message) (*SendResult, error) {
 	// verify params for sending email
-	p.verifyParams()
-
-	//check the total file size and path
-	var totalSize int64
-	for _, f := range p.attachmentFiles {
-		fi, e := os.Stat(f)
-		if e != nil {
-			return e
-		}
-		// get the size
-		totalSize += fi.Size()
-	}
-
-	if totalSize > postmarkMaxFileSize {
-		return errors.New("gomailer: max attachment size for postmark is 5MB")
+	if err := p.verifyParams(msg); err != nil {
+		return nil, err
 	}
 
-	// build attachment
-	for _, f := range p.attachmentFiles {
-		a := attachment{}
-		err := a.ReadFromFile(f)
-		if err != nil {
-			return err
-		}
-		a.Disposition = "attachment"
-		p.attachments = append(p.attachments, a)
+	// wait for the client side rate limiter if any
+	ctx, err := p.c.limiter.wait(ctx, "postmark", msg.receipents())
+	if err != nil {
+		return nil, err
 	}
 
-	// build attachment inine
-	for _, f := range p.attachmentInlineFiles {
-		a := attachment{}
-		err := a.ReadFromFile(f)
-		if err != nil {
-			return err
-		}
-		a.Disposition = "inline"
-		p.attachments = append(p.attachments, a)
-	}
+	return p.processPostmarkRequest(ctx, msg, p.params(msg))
+}
 
-	// build params
+// params return the request body of an email
+func (p *postmark) params(msg *Message) mapData {
+	// build params, a template brings its own subject
 	params := mapData{
-		"From":    p.from.format(),
-		"Subject": p.subject,
+		"From": msg.From.format(),
+	}
+	if msg.Template == "" {
+		params["Subject"] = msg.Subject
+	} else {
+		// numeric ids are template ids, anything else is an alias
+		if id, err := strconv.Atoi(msg.Template); err == nil {
+			params["TemplateId"] = id
+		} else {
+			params["TemplateAlias"] = msg.Template
+		}
+		model := msg.TemplateData
+		if model == nil {
+			model = map[string]interface{}{}
+		}
+		params["TemplateModel"] = model
 	}
 
-	if len(p.toList) > 0 {
+	if len(msg.To) > 0 {
 		var tList []string
-		for _, a := range p.toList {
+		for _, a := range msg.To {
 			tList = append(tList, a.format())
 		}
 		params["To"] = strings.Join(tList, ",")
 	}
 
-	if len(p.ccList) > 0 {
+	if len(msg.Cc) > 0 {
 		var cList []string
-		for _, a := range p.ccList {
+		for _, a := range msg.Cc {
 			cList = append(cList, a.format())
 		}
 		params["Cc"] = strings.Join(cList, ",")
 	}
 
-	if len(p.bccList) > 0 {
+	if len(msg.Bcc) > 0 {
 		var bList []string
-		for _, a := range p.bccList {
+		for _, a := range msg.Bcc {
 			bList = append(bList, a.format())
 		}
 		params["Bcc"] = strings.Join(bList, ",")
 	}
 
-	if p.replyTo.Email != "" {
-		params["ReplyTo"] = p.replyTo
+	if msg.ReplyTo.Email != "" {
+		params["ReplyTo"] = msg.ReplyTo.format()
 	}
-	if len(p.bodyText) > 0 {
-		params["TextBody"] = p.bodyText
+	if len(msg.Text) > 0 {
+		params["TextBody"] = msg.Text
 	}
 
-	if len(p.bodyHTML) > 0 {
-		params["HtmlBody"] = p.bodyHTML
+	if len(msg.HTML) > 0 {
+		params["HtmlBody"] = msg.HTML
 	}
 
 	// add attachment if exist
-	if len(p.attachments) > 0 {
+	if len(msg.Attachments) > 0 {
 		var pAttachments []postmarkAttachment
-		for _, a := range p.attachments {
+		for _, f := range msg.Attachments {
+			a := f.encode()
 			pAttachments = append(pAttachments, postmarkAttachment{
 				Name:        a.FileName,
 				Content:     a.Content,
@@ -228,39 +152,126 @@ func (p *postmark) Send() error {
 		params["Attachments"] = pAttachments
 	}
 
-	return p.processPostmarkRequest(params)
+	return params
+}
+
+// batchSize return the max emails per batch request
+func (postmark) batchSize() int {
+	return postmarkMaxBatch
+}
+
+// maxBatchPayload return the max size in bytes of a batch request, every
+// email of the batch carries the attachments
+func (postmark) maxBatchPayload() int64 {
+	return postmarkMaxBatchPayload
+}
+
+// sendBatch send one email per receipent in a single request, postmark
+// reports the status of every email so a receipent may fail alone
+func (p *postmark) sendBatch(ctx context.Context, msg *Message, recipients []Recipient) ([]RecipientResult, error) {
+	messages := []mapData{}
+	for _, r := range recipients {
+		messages = append(messages, p.params(msg.personalize(r)))
+	}
+	// the template batch wraps the emails, the plain batch is a bare array
+	var body interface{} = messages
+	if msg.Template != "" {
+		body = mapData{"Messages": messages}
+	}
+
+	// wait for the client side rate limiter if any
+	ctx, err := p.c.limiter.wait(ctx, "postmark", len(recipients))
+	if err != nil {
+		return nil, err
+	}
+
+	status, bodyByte, err := p.post(ctx, p.batchURL(msg.Template != ""), body)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, p.providerError(status, bodyByte)
+	}
+
+	// the emails of the response are in the order of the request
+	result := []struct {
+		ErrorCode int    `json:"ErrorCode"`
+		Message   string `json:"Message"`
+		MessageID string `json:"MessageID"`
+	}{}
+	if err := json.Unmarshal(bodyByte, &result); err != nil || len(result) != len(recipients) {
+		return nil, newProviderError("postmark", status, bodyByte, "", "unexpected batch response")
+	}
+	results := []RecipientResult{}
+	for i, r := range recipients {
+		res := RecipientResult{Email: r.Address.Email, MessageID: result[i].MessageID}
+		if result[i].ErrorCode != 0 {
+			// postmark answers 422 for a rejected email of a single send
+			res.Err = newProviderError("postmark", http.StatusUnprocessableEntity, bodyByte, strconv.Itoa(result[i].ErrorCode), result[i].Message)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// maxAttachmentSize return the max total size in bytes of the attachments
+func (postmark) maxAttachmentSize() int64 {
+	return postmarkMaxFileSize
+}
+
+// maxReceipents return the max to, cc and bcc receipents per email
+func (postmark) maxReceipents() int {
+	return postmarkMaxReceipents
 }
 
 // verifyParams verify the required params
-func (p postmark) verifyParams() {
+func (p postmark) verifyParams(msg *Message) error {
+	v := validation{service: "postmark"}
 	if p.configs.AccountToken == "" &&
 		p.configs.ServerToken == "" {
-		panic("gomailer: for postmarkapp you must provide AccountToken or ServerToken in config")
+		v.add(ErrMissingCredentials, "for postmarkapp you must provide AccountToken or ServerToken in config")
 	}
-	if p.from.Email == "" {
-		panic("gomailer: you must provide from")
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), postmarkMaxReceipents)
+	v.verifyBody(msg)
+	v.verifyAttachments(msg.attachmentSize(), postmarkMaxFileSize)
+	return v.err()
+}
+
+// processPostmarkRequest perform a post request with content type application/json for postmark
+func (p *postmark) processPostmarkRequest(ctx context.Context, msg *Message, bodyParams map[string]interface{}) (*SendResult, error) {
+	url := p.messageURL()
+	if msg.Template != "" {
+		url = p.templateURL()
 	}
-	if len(p.toList) <= 0 {
-		panic("gomailer: you must provide at least one receipent")
+	status, bodyByte, err := p.post(ctx, url, bodyParams)
+	if err != nil {
+		return nil, err
 	}
-	if len(p.toList)+len(p.ccList)+len(p.bccList) > postmarkMaxReceipents {
-		panic(fmt.Sprintf("mailer: total number of receipents including to/cc/bcc can not be greater than %d for postmark", postmarkMaxReceipents))
+	if status != http.StatusOK {
+		return nil, p.providerError(status, bodyByte)
 	}
-	if p.bodyText == "" && p.bodyHTML == "" {
-		panic("gomailer: you must provide a Text or HTML body")
+	result := struct {
+		MessageID string `json:"MessageID"`
+	}{}
+	_ = json.Unmarshal(bodyByte, &result)
+	r := newSendResult("postmark", status, bodyByte, msg.To, msg.Cc, msg.Bcc)
+	if result.MessageID != "" {
+		r.MessageIDs = []string{result.MessageID}
 	}
+	return r, nil
 }
 
-// processPostmarkRequest perform a post request with content type application/json for postmark
-func (p *postmark) processPostmarkRequest(bodyParams map[string]interface{}) error {
+// post perform the request and return the status and body of the response
+func (p *postmark) post(ctx context.Context, url string, bodyParams interface{}) (int, []byte, error) {
 	body, err := toJSON(bodyParams)
 	if err != nil {
-		return err
+		return 0, nil, newLocalError("postmark", err)
 	}
-	req, errReq := http.NewRequest("POST", p.messageURL(), bytes.NewBuffer(body))
+	req, errReq := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 
 	if errReq != nil {
-		return errReq
+		return 0, nil, newLocalError("postmark", errReq)
 	}
 
 	if p.configs.AccountToken != "" {
@@ -275,11 +286,27 @@ func (p *postmark) processPostmarkRequest(bodyParams map[string]interface{}) err
 
 	resp, err := p.c.getDefaultClient().Do(req)
 	if err != nil {
-		return err
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyByte, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return errors.New(string(body))
+	return resp.StatusCode, bodyByte, nil
+}
+
+// providerError decode a postmark error response
+func (postmark) providerError(status int, body []byte) error {
+	result := struct {
+		ErrorCode int    `json:"ErrorCode"`
+		Message   string `json:"Message"`
+	}{}
+	_ = json.Unmarshal(body, &result)
+	code := ""
+	if result.ErrorCode != 0 {
+		code = strconv.Itoa(result.ErrorCode)
 	}
-	return nil
+	return newProviderError("postmark", status, body, code, result.Message)
 }
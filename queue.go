@@ -0,0 +1,292 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// QueuePending describes a message waiting for its next attempt
+	QueuePending QueueState = "pending"
+	// QueueDead describes a message which failed for good, it stays in the
+	// store until it is requeued or deleted
+	QueueDead QueueState = "dead"
+
+	// defaultQueueWorkers describes the concurrent deliveries of a queue
+	defaultQueueWorkers = 4
+	// defaultQueuePollInterval describes how often the store is checked for due messages
+	defaultQueuePollInterval = time.Second
+)
+
+// defaultQueueRetry describes the retries of a queue, spread over about half an hour
+var defaultQueueRetry = RetryPolicy{
+	MaxAttempts: 6,
+	BaseBackoff: 30 * time.Second,
+	MaxBackoff:  15 * time.Minute,
+	Jitter:      0.2,
+}
+
+type (
+	// QueueState describes the state of a queued message
+	QueueState string
+
+	// QueueItem describes a message persisted in a queue store, attachments included
+	QueueItem struct {
+		ID          string     `json:"id"`
+		Message     Message    `json:"message"`
+		State       QueueState `json:"state"`
+		Attempts    int        `json:"attempts"`             // Attempts represents the failed deliveries so far
+		NextAttempt time.Time  `json:"next_attempt"`         // NextAttempt represents the earliest time of the next delivery
+		LastError   string     `json:"last_error,omitempty"` // LastError represents the error of the last delivery
+		CreatedAt   time.Time  `json:"created_at"`
+	}
+
+	// QueueStore describes the storage of a queue, the items are removed once
+	// delivered. A store must be safe for concurrent use
+	QueueStore interface {
+		// Put save an item, replacing the one with the same id
+		Put(ctx context.Context, item *QueueItem) error
+		// Get return the item with the id, ErrNotQueued if there is none
+		Get(ctx context.Context, id string) (*QueueItem, error)
+		// Delete remove the item with the id, removing a missing item is not an error
+		Delete(ctx context.Context, id string) error
+		// Due return at most limit pending items whose next attempt is not after now, the earliest first
+		Due(ctx context.Context, now time.Time, limit int) ([]*QueueItem, error)
+		// List return the items in the state, the oldest first
+		List(ctx context.Context, state QueueState) ([]*QueueItem, error)
+	}
+
+	// Queue describes a durable outbound queue, a message is persisted by
+	// Enqueue and delivered in the background by Run. Failed deliveries are
+	// retried with backoff until the attempts are exhausted or the error is
+	// permanent, the message is then kept as dead. A message is delivered at
+	// least once, a crash during a send may deliver it twice
+	Queue struct {
+		store    QueueStore
+		sender   Sender
+		retry    RetryPolicy
+		workers  int
+		poll     time.Duration
+		onResult func(item QueueItem, r *SendResult, err error)
+		wake     chan struct{}
+	}
+)
+
+// NewQueue return a queue persisting messages in store and delivering them through s
+func NewQueue(store QueueStore, s Sender) *Queue {
+	return &Queue{
+		store:   store,
+		sender:  s,
+		retry:   defaultQueueRetry,
+		workers: defaultQueueWorkers,
+		poll:    defaultQueuePollInterval,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Retry sets the attempts and backoff of failed deliveries, MaxAttempts
+// <= 1 moves a message to the dead state on its first failure
+func (q *Queue) Retry(p RetryPolicy) *Queue {
+	q.retry = p
+	return q
+}
+
+// Workers sets the number of concurrent deliveries, n <= 0 means 1
+func (q *Queue) Workers(n int) *Queue {
+	if n <= 0 {
+		n = 1
+	}
+	q.workers = n
+	return q
+}
+
+// PollInterval sets how often the store is checked for due messages
+func (q *Queue) PollInterval(d time.Duration) *Queue {
+	if d > 0 {
+		q.poll = d
+	}
+	return q
+}
+
+// OnResult sets the callback receiving the outcome of every delivery, err is
+// nil once the message is sent. The item reflects the state after the delivery
+func (q *Queue) OnResult(fn func(item QueueItem, r *SendResult, err error)) *Queue {
+	q.onResult = fn
+	return q
+}
+
+// Enqueue persist a message for delivery and return its id, the message is
+// copied so later changes do not leak
+func (q *Queue) Enqueue(ctx context.Context, m *Message) (string, error) {
+	now := time.Now().UTC()
+	item := &QueueItem{
+		ID:          uuid.New().String(),
+		Message:     *m.clone(),
+		State:       QueuePending,
+		NextAttempt: now,
+		CreatedAt:   now,
+	}
+	// every delivery attempt of the item is the same message to the provider
+	if item.Message.ID == "" {
+		item.Message.ID = item.ID
+	}
+	if err := q.store.Put(ctx, item); err != nil {
+		return "", err
+	}
+	q.notify()
+	return item.ID, nil
+}
+
+// Requeue move a dead message back to pending with its attempts reset
+func (q *Queue) Requeue(ctx context.Context, id string) error {
+	item, err := q.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	item.State = QueuePending
+	item.Attempts = 0
+	item.NextAttempt = time.Now().UTC()
+	if err := q.store.Put(ctx, item); err != nil {
+		return err
+	}
+	q.notify()
+	return nil
+}
+
+// Dead return the messages which failed for good
+func (q *Queue) Dead(ctx context.Context) ([]*QueueItem, error) {
+	return q.store.List(ctx, QueueDead)
+}
+
+// notify wake the dispatcher without blocking
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run deliver due messages until ctx is cancelled, then wait for the
+// deliveries in flight. An interrupted delivery does not count as an attempt.
+// A store failure stops the queue and is returned
+func (q *Queue) Run(ctx context.Context) error {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		inflight = map[string]bool{}
+		storeErr error
+	)
+	ticker := time.NewTicker(q.poll)
+	defer ticker.Stop()
+	defer wg.Wait()
+
+	for {
+		mu.Lock()
+		err, busy := storeErr, len(inflight)
+		mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		if free := q.workers - busy; free > 0 {
+			// items in flight are still pending in the store, ask for enough to skip them
+			items, err := q.store.Due(ctx, time.Now().UTC(), free+busy)
+			if err != nil && ctx.Err() == nil {
+				return err
+			}
+			for _, item := range items {
+				mu.Lock()
+				if inflight[item.ID] || len(inflight) >= q.workers {
+					mu.Unlock()
+					continue
+				}
+				inflight[item.ID] = true
+				mu.Unlock()
+
+				wg.Add(1)
+				go func(item *QueueItem) {
+					defer wg.Done()
+					err := q.deliver(ctx, item)
+					mu.Lock()
+					delete(inflight, item.ID)
+					if err != nil && storeErr == nil {
+						storeErr = err
+					}
+					mu.Unlock()
+					q.notify()
+				}(item)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// deliver send an item and record the outcome in the store, only store
+// failures are returned
+func (q *Queue) deliver(ctx context.Context, item *QueueItem) error {
+	msg := item.Message
+	r, err := q.sender.Send(ctx, &msg)
+	if err == nil {
+		// a failed delete sends the message again, which is the at least once promise
+		if err := q.store.Delete(context.Background(), item.ID); err != nil {
+			return err
+		}
+		q.report(*item, r, nil)
+		return nil
+	}
+	if ctx.Err() != nil {
+		// stopped while sending, the message is tried again on the next run
+		return nil
+	}
+
+	item.Attempts++
+	item.LastError = err.Error()
+	wait, retry := q.retry.wait(item.Attempts, 0)
+	if !queueRetryable(err) || !retry {
+		item.State = QueueDead
+	} else {
+		var rerr *RateLimitError
+		if errors.As(err, &rerr) && rerr.Wait > wait {
+			wait = rerr.Wait
+		}
+		item.NextAttempt = time.Now().UTC().Add(wait)
+	}
+	if err := q.store.Put(context.Background(), item); err != nil {
+		return err
+	}
+	q.report(*item, r, err)
+	return nil
+}
+
+// report call the result callback if any
+func (q *Queue) report(item QueueItem, r *SendResult, err error) {
+	if q.onResult != nil {
+		q.onResult(item, r, err)
+	}
+}
+
+// queueRetryable reports whether a later delivery may succeed, invalid
+// emails, unsupported features, requests which can not be built and refused
+// requests never will
+func queueRetryable(err error) bool {
+	var verr *ValidationError
+	var lerr *LocalError
+	if errors.As(err, &verr) || errors.As(err, &lerr) || errors.Is(err, ErrUnsupported) {
+		return false
+	}
+	var perr *ProviderError
+	if errors.As(err, &perr) {
+		return perr.Retryable()
+	}
+	return true
+}
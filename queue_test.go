@@ -0,0 +1,153 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thedevsaddam/gomailer/gomailertest"
+)
+
+func TestQueueRetryable(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"validation", &ValidationError{Service: "sendgrid", Violations: []error{ErrAttachmentTooLarge}}, false},
+		{"unsupported", unsupportedTemplate("jangomail", &Message{Template: "welcome"}), false},
+		{"local", newLocalError("sendgrid", errors.New("bad url")), false},
+		{"wrapped local", fmt.Errorf("failover: %w", newLocalError("sendgrid", errors.New("bad url"))), false},
+		{"refused", newProviderError("sendgrid", http.StatusBadRequest, nil, "", ""), false},
+		{"unavailable", newProviderError("sendgrid", http.StatusServiceUnavailable, nil, "", ""), true},
+		{"network", errors.New("connection reset"), true},
+	}
+	for _, c := range cases {
+		if got := queueRetryable(c.err); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestQueueDeliver(t *testing.T) {
+	srv := gomailertest.NewSendGrid("key")
+	defer srv.Close()
+	msg := Message{
+		From:    Address{Email: "john@example.com"},
+		To:      []Address{{Email: "jane@example.com"}},
+		Subject: "subject",
+		Text:    "text",
+	}
+	cases := []struct {
+		name    string
+		baseURL string
+		fail    bool
+		state   QueueState
+	}{
+		// a base url which can not be parsed fails every attempt the same way
+		{"local failure", "http://[::1", false, QueueDead},
+		{"provider unavailable", srv.BaseURL(), true, QueuePending},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store, err := NewFileQueueStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, _ := NewSender(SENDGRID, Configs{APIKey: "key", BaseURL: c.baseURL})
+			q := NewQueue(store, s)
+			id, err := q.Enqueue(context.Background(), &msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.fail {
+				srv.Fail(http.StatusServiceUnavailable, "down")
+			}
+			item, _ := store.Get(context.Background(), id)
+			if err := q.deliver(context.Background(), item); err != nil {
+				t.Fatal(err)
+			}
+			item, err = store.Get(context.Background(), id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item.State != c.state || item.Attempts != 1 {
+				t.Errorf("got state %v after %d attempts, want %v after 1", item.State, item.Attempts, c.state)
+			}
+		})
+	}
+}
+
+func TestQueueMessageID(t *testing.T) {
+	store := NewMemoryQueueStore()
+	q := NewQueue(store, &stubSender{})
+	id, err := q.Enqueue(context.Background(), &Message{From: Address{Email: "john@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, _ := store.Get(context.Background(), id)
+	if item.Message.ID != id {
+		t.Errorf("got message id %q, want the item id %q", item.Message.ID, id)
+	}
+
+	id, _ = q.Enqueue(context.Background(), &Message{ID: "order-1042"})
+	if item, _ = store.Get(context.Background(), id); item.Message.ID != "order-1042" {
+		t.Errorf("got message id %q, want the caller id kept", item.Message.ID)
+	}
+}
+
+func TestFileQueueStoreCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileQueueStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reported := []string{}
+	store.OnCorrupt(func(path string, err error) {
+		reported = append(reported, filepath.Base(path))
+	})
+	q := NewQueue(store, &stubSender{})
+	first, _ := q.Enqueue(context.Background(), &Message{From: Address{Email: "john@example.com"}})
+	second, _ := q.Enqueue(context.Background(), &Message{From: Address{Email: "john@example.com"}})
+	// a file truncated by a crash or a full disk before the store is read
+	if err := ioutil.WriteFile(filepath.Join(dir, "truncated.json"), []byte(`{"id":"truncated","message":{`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := store.Due(context.Background(), time.Now(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d due items, want 2", len(items))
+	}
+
+	// a file damaged once indexed is found when the item is read
+	if err := ioutil.WriteFile(filepath.Join(dir, second+".json"), []byte(`{"id":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	items, err = store.Due(context.Background(), time.Now(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].ID != first {
+		t.Fatalf("got %d due items, want only %s", len(items), first)
+	}
+	if len(reported) != 2 || reported[0] != "truncated.json" || reported[1] != second+".json" {
+		t.Errorf("got reported %v", reported)
+	}
+	for _, name := range []string{"truncated.json", second + ".json"} {
+		if _, err := os.Stat(filepath.Join(dir, name+queueCorruptExt)); err != nil {
+			t.Errorf("%s was not moved aside: %v", name, err)
+		}
+	}
+	if _, err := store.Due(context.Background(), time.Now(), 0); err != nil || len(reported) != 2 {
+		t.Errorf("a moved file was read again: %v %v", err, reported)
+	}
+}
@@ -0,0 +1,373 @@
+package gomailer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// queueFileExt describes the file extension of a queue item
+	queueFileExt = ".json"
+	// queueCorruptExt describes the extension added to an item file which can not be read
+	queueCorruptExt = ".corrupt"
+)
+
+type (
+	// MemoryQueueStore describes a queue store kept in memory, the messages
+	// do not survive the process. Meant for tests and for queues whose
+	// durability is not a concern
+	MemoryQueueStore struct {
+		mu    sync.Mutex
+		items map[string]*QueueItem
+	}
+
+	// FileQueueStore describes a queue store keeping one json file per item
+	// in a directory. An item is written to a temporary file, synced and
+	// renamed, then the directory is synced so a crash neither leaves a
+	// partial item nor loses a stored one. The state and next attempt of
+	// every item are kept in memory, read from the directory on first use,
+	// so Due and List only read the files of the items they return. A file
+	// which can not be read is renamed with a .corrupt extension and
+	// reported to the OnCorrupt callback. The directory must not be shared
+	// by two processes
+	FileQueueStore struct {
+		mu        sync.Mutex
+		dir       string
+		index     map[string]queueIndexEntry // index represents the items of the directory, nil until first read
+		onCorrupt func(path string, err error)
+	}
+
+	// queueIndexEntry describes the fields of an item needed to pick it
+	queueIndexEntry struct {
+		State       QueueState `json:"state"`
+		NextAttempt time.Time  `json:"next_attempt"`
+		CreatedAt   time.Time  `json:"created_at"`
+	}
+)
+
+// NewMemoryQueueStore return an empty in memory queue store
+func NewMemoryQueueStore() *MemoryQueueStore {
+	return &MemoryQueueStore{items: map[string]*QueueItem{}}
+}
+
+// Put save an item, replacing the one with the same id
+func (s *MemoryQueueStore) Put(ctx context.Context, item *QueueItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[item.ID] = item.clone()
+	return nil
+}
+
+// Get return the item with the id, ErrNotQueued if there is none
+func (s *MemoryQueueStore) Get(ctx context.Context, id string) (*QueueItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return nil, ErrNotQueued
+	}
+	return item.clone(), nil
+}
+
+// Delete remove the item with the id
+func (s *MemoryQueueStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, id)
+	return nil
+}
+
+// Due return at most limit pending items whose next attempt is not after now, the earliest first
+func (s *MemoryQueueStore) Due(ctx context.Context, now time.Time, limit int) ([]*QueueItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := []*QueueItem{}
+	for _, item := range s.items {
+		if item.due(now) {
+			items = append(items, item.clone())
+		}
+	}
+	return dueItems(items, limit), nil
+}
+
+// List return the items in the state, the oldest first
+func (s *MemoryQueueStore) List(ctx context.Context, state QueueState) ([]*QueueItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := []*QueueItem{}
+	for _, item := range s.items {
+		if item.State == state {
+			items = append(items, item.clone())
+		}
+	}
+	return oldestItems(items), nil
+}
+
+// NewFileQueueStore return a queue store in dir, the directory is created if needed
+func NewFileQueueStore(dir string) (*FileQueueStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileQueueStore{dir: dir}, nil
+}
+
+// OnCorrupt sets the callback receiving the item files which can not be read,
+// path is the file name before it was moved aside
+func (s *FileQueueStore) OnCorrupt(fn func(path string, err error)) *FileQueueStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onCorrupt = fn
+	return s
+}
+
+// Put save an item, replacing the one with the same id
+func (s *FileQueueStore) Put(ctx context.Context, item *QueueItem) error {
+	if err := validQueueID(item.ID); err != nil {
+		return err
+	}
+	b, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp, err := ioutil.TempFile(s.dir, ".tmp-"+item.ID+"-")
+	if err != nil {
+		return err
+	}
+	// the temporary file is gone after the rename, removing it only matters on failure
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path(item.ID)); err != nil {
+		return err
+	}
+	if s.index != nil {
+		s.index[item.ID] = queueIndexEntry{State: item.State, NextAttempt: item.NextAttempt, CreatedAt: item.CreatedAt}
+	}
+	return s.syncDir()
+}
+
+// syncDir flush the directory entries so a renamed item survives a crash
+func (s *FileQueueStore) syncDir() error {
+	d, err := os.Open(s.dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Get return the item with the id, ErrNotQueued if there is none
+func (s *FileQueueStore) Get(ctx context.Context, id string) (*QueueItem, error) {
+	if err := validQueueID(id); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, err := s.read(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotQueued
+	}
+	return item, err
+}
+
+// Delete remove the item with the id
+func (s *FileQueueStore) Delete(ctx context.Context, id string) error {
+	if err := validQueueID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	delete(s.index, id)
+	return nil
+}
+
+// Due return at most limit pending items whose next attempt is not after now, the earliest first
+func (s *FileQueueStore) Due(ctx context.Context, now time.Time, limit int) ([]*QueueItem, error) {
+	return s.load(func(stubs []*QueueItem) []*QueueItem {
+		items := []*QueueItem{}
+		for _, item := range stubs {
+			if item.due(now) {
+				items = append(items, item)
+			}
+		}
+		return dueItems(items, limit)
+	})
+}
+
+// List return the items in the state, the oldest first
+func (s *FileQueueStore) List(ctx context.Context, state QueueState) ([]*QueueItem, error) {
+	return s.load(func(stubs []*QueueItem) []*QueueItem {
+		items := []*QueueItem{}
+		for _, item := range stubs {
+			if item.State == state {
+				items = append(items, item)
+			}
+		}
+		return oldestItems(items)
+	})
+}
+
+// load pass the indexed items, without their message, to pick and read the
+// picked ones in order. A file which can not be read is moved aside and
+// reported instead of failing the whole call
+func (s *FileQueueStore) load(pick func(stubs []*QueueItem) []*QueueItem) ([]*QueueItem, error) {
+	s.mu.Lock()
+	corrupt := map[string]error{}
+	if err := s.loadIndex(corrupt); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	stubs := []*QueueItem{}
+	for id, e := range s.index {
+		stubs = append(stubs, &QueueItem{ID: id, State: e.State, NextAttempt: e.NextAttempt, CreatedAt: e.CreatedAt})
+	}
+	items := []*QueueItem{}
+	for _, stub := range pick(stubs) {
+		item, err := s.read(s.path(stub.ID))
+		if errors.Is(err, os.ErrNotExist) {
+			delete(s.index, stub.ID)
+			continue
+		}
+		if err != nil {
+			s.moveAside(stub.ID, err, corrupt)
+			continue
+		}
+		items = append(items, item)
+	}
+	onCorrupt := s.onCorrupt
+	s.mu.Unlock()
+
+	// the callback runs without the lock so it may use the store
+	if onCorrupt != nil {
+		paths := []string{}
+		for path := range corrupt {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			onCorrupt(path, corrupt[path])
+		}
+	}
+	return items, nil
+}
+
+// loadIndex read the state of every item of the directory once, the lock must be held
+func (s *FileQueueStore) loadIndex(corrupt map[string]error) error {
+	if s.index != nil {
+		return nil
+	}
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	s.index = map[string]queueIndexEntry{}
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || filepath.Ext(e.Name()) != queueFileExt {
+			continue
+		}
+		id := strings.TrimSuffix(e.Name(), queueFileExt)
+		b, err := ioutil.ReadFile(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			s.moveAside(id, err, corrupt)
+			continue
+		}
+		entry := queueIndexEntry{}
+		if err := json.Unmarshal(b, &entry); err != nil {
+			s.moveAside(id, err, corrupt)
+			continue
+		}
+		s.index[id] = entry
+	}
+	return nil
+}
+
+// moveAside rename the file of an item which can not be read so later calls
+// skip it, the lock must be held
+func (s *FileQueueStore) moveAside(id string, err error, corrupt map[string]error) {
+	path := s.path(id)
+	if rerr := os.Rename(path, path+queueCorruptExt); rerr != nil {
+		err = fmt.Errorf("%w, moving it aside failed: %v", err, rerr)
+	}
+	delete(s.index, id)
+	corrupt[path] = err
+}
+
+// read decode an item file
+func (s *FileQueueStore) read(path string) (*QueueItem, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	item := &QueueItem{}
+	if err := json.Unmarshal(b, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// path return the file of an item
+func (s *FileQueueStore) path(id string) string {
+	return filepath.Join(s.dir, id+queueFileExt)
+}
+
+// validQueueID reject ids which would escape the store directory
+func validQueueID(id string) error {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return ErrNotQueued
+	}
+	return nil
+}
+
+// due reports whether a pending item may be delivered at now
+func (item *QueueItem) due(now time.Time) bool {
+	return item.State == QueuePending && !item.NextAttempt.After(now)
+}
+
+// clone return a deep copy of the item
+func (item *QueueItem) clone() *QueueItem {
+	c := *item
+	c.Message = *item.Message.clone()
+	return &c
+}
+
+// dueItems sort the items by next attempt and keep at most limit, limit <= 0 keeps all
+func dueItems(items []*QueueItem, limit int) []*QueueItem {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].NextAttempt.Before(items[j].NextAttempt)
+	})
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+// oldestItems sort the items by creation time
+func oldestItems(items []*QueueItem) []*QueueItem {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+	return items
+}
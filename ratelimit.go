@@ -0,0 +1,195 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// RateLimit describes a client side token bucket limiter, the zero value
+	// disables it. A send waits for its tokens unless the wait would exceed
+	// the context deadline, in which case a *RateLimitError is returned
+	RateLimit struct {
+		RequestsPerSecond   float64 // RequestsPerSecond represents the sustained request rate, <= 0 means unlimited
+		Burst               int     // Burst represents the requests allowed at once, defaults to RequestsPerSecond rounded up
+		RecipientsPerSecond float64 // RecipientsPerSecond represents the sustained to/cc/bcc receipent rate, <= 0 means unlimited
+	}
+
+	// RateLimitError describes a send refused because waiting for the rate
+	// limiter would exceed the context deadline
+	RateLimitError struct {
+		Service string        // Service represents the driver which is limited
+		Wait    time.Duration // Wait represents the time needed for the tokens to be available
+	}
+
+	// limiter describes the request and receipent buckets of a driver
+	limiter struct {
+		mu         sync.Mutex
+		requests   *tokenBucket
+		recipients *tokenBucket
+	}
+
+	// tokenBucket describes a bucket refilled at rate tokens per second up to
+	// burst, tokens may go negative to queue waiting sends
+	tokenBucket struct {
+		rate   float64
+		burst  float64
+		tokens float64
+		last   time.Time
+	}
+)
+
+// Error implements the error interface
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("gomailer: rate limit of %s exceeded, tokens available in %s", e.Service, e.Wait)
+}
+
+// Unwrap makes the error comparable with ErrRateLimited using errors.Is
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// limiterKey describes the key of a shared limiter
+type limiterKey struct {
+	d       driver
+	r       RateLimit
+	account string // account represents a hash of the fields identifying the provider account
+}
+
+var (
+	// limiters holds one limiter per driver, account and rate, shared by every
+	// instance so creating a mailer per email does not reset the buckets
+	limiters   = map[limiterKey]*limiter{}
+	limitersMu sync.Mutex
+)
+
+// sharedLimiter return the limiter of a driver for the config, nil if it is disabled
+func sharedLimiter(d driver, c Configs) *limiter {
+	r := c.RateLimit
+	if r.RequestsPerSecond <= 0 && r.RecipientsPerSecond <= 0 {
+		return nil
+	}
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	key := limiterKey{d: d, r: r, account: limiterAccount(c)}
+	if l, ok := limiters[key]; ok {
+		return l
+	}
+	l := &limiter{}
+	if r.RequestsPerSecond > 0 {
+		burst := float64(r.Burst)
+		if burst <= 0 {
+			burst = math.Ceil(r.RequestsPerSecond)
+		}
+		l.requests = newTokenBucket(r.RequestsPerSecond, burst)
+	}
+	if r.RecipientsPerSecond > 0 {
+		l.recipients = newTokenBucket(r.RecipientsPerSecond, math.Ceil(r.RecipientsPerSecond))
+	}
+	limiters[key] = l
+	return l
+}
+
+// limiterAccount return a hash of the config fields identifying an account,
+// so separate accounts of a driver get separate buckets. The secret half of
+// a key pair is left out, the public half already tells the account
+func limiterAccount(c Configs) string {
+	fields := []string{
+		c.APIKey, c.ServerToken, c.AccountToken, c.PublicKey, c.AccessKey,
+		c.Username, c.ServerID, c.Domain, c.Host, strconv.Itoa(c.Port), c.Region, c.BaseURL,
+	}
+	return sha256Hex([]byte(strings.Join(fields, "\x00")))
+}
+
+// newTokenBucket return a full bucket
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// limiterGrant describes the tokens taken for a send, carried by the request
+// context so the retry transport takes them again for every retried attempt
+type limiterGrant struct {
+	l       *limiter
+	service string
+	n       int
+}
+
+// limiterGrantKey describes the context key of a limiterGrant
+type limiterGrantKey struct{}
+
+// wait block until a request for n receipents is allowed and return a context
+// carrying the grant, a nil limiter never blocks
+func (l *limiter) wait(ctx context.Context, service string, n int) (context.Context, error) {
+	if l == nil {
+		return ctx, nil
+	}
+	if err := l.take(ctx, service, n); err != nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, limiterGrantKey{}, limiterGrant{l: l, service: service, n: n}), nil
+}
+
+// retryWait block until the retried attempt of a request made with ctx is
+// allowed, requests made without the limiter never block
+func retryWait(ctx context.Context) error {
+	g, ok := ctx.Value(limiterGrantKey{}).(limiterGrant)
+	if !ok {
+		return nil
+	}
+	return g.l.take(ctx, g.service, g.n)
+}
+
+// take block until a request for n receipents is allowed and remove its tokens
+func (l *limiter) take(ctx context.Context, service string, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	d := l.requests.delay(now, 1)
+	if rd := l.recipients.delay(now, float64(n)); rd > d {
+		d = rd
+	}
+	if deadline, ok := ctx.Deadline(); ok && now.Add(d).After(deadline) {
+		l.mu.Unlock()
+		return &RateLimitError{Service: service, Wait: d}
+	}
+	l.requests.take(1)
+	l.recipients.take(float64(n))
+	l.mu.Unlock()
+
+	if d <= 0 {
+		return nil
+	}
+	if err := sleepContext(ctx, d); err != nil {
+		// give the tokens back, the send did not happen
+		l.mu.Lock()
+		l.requests.take(-1)
+		l.recipients.take(-float64(n))
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// delay refill the bucket and return the wait until n tokens are available
+func (b *tokenBucket) delay(now time.Time, n float64) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+// take remove n tokens from the bucket
+func (b *tokenBucket) take(n float64) {
+	if b != nil {
+		b.tokens -= n
+	}
+}
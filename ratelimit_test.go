@@ -0,0 +1,75 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/thedevsaddam/gomailer/gomailertest"
+)
+
+func TestRateLimitLocalFailures(t *testing.T) {
+	c := Configs{Username: "user", APIKey: "local-failures", RateLimit: RateLimit{RequestsPerSecond: 0.01, Burst: 1}}
+	s, _ := NewSender(MADMIMI, c)
+	msg := &Message{
+		From: Address{Email: "john@example.com"},
+		To:   []Address{{Email: "jane@example.com"}, {Email: "tom@example.com"}},
+		Text: "body",
+	}
+	// a send which never reaches the provider takes no token
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, err := s.Send(ctx, msg)
+		cancel()
+		if !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("send %d: got %v, want ErrUnsupported", i, err)
+		}
+	}
+}
+
+func TestRateLimitPerAccount(t *testing.T) {
+	r := RateLimit{RequestsPerSecond: 1}
+	a := sharedLimiter(MAILGUN, Configs{APIKey: "account-a", Domain: "a.example.com", RateLimit: r})
+	b := sharedLimiter(MAILGUN, Configs{APIKey: "account-b", Domain: "b.example.com", RateLimit: r})
+	if a == b {
+		t.Error("separate accounts share a bucket")
+	}
+	if again := sharedLimiter(MAILGUN, Configs{APIKey: "account-a", Domain: "a.example.com", RateLimit: r}); again != a {
+		t.Error("the same account got a new bucket")
+	}
+	if other := sharedLimiter(SENDGRID, Configs{APIKey: "account-a", Domain: "a.example.com", RateLimit: r}); other == a {
+		t.Error("separate drivers share a bucket")
+	}
+	if sharedLimiter(MAILGUN, Configs{APIKey: "account-a"}) != nil {
+		t.Error("a zero rate limit is not disabled")
+	}
+}
+
+func TestRateLimitRetry(t *testing.T) {
+	srv := gomailertest.NewSendGrid("retry-limited")
+	defer srv.Close()
+	s, _ := NewSender(SENDGRID, Configs{
+		APIKey:    "retry-limited",
+		BaseURL:   srv.BaseURL(),
+		Retry:     RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond},
+		RateLimit: RateLimit{RequestsPerSecond: 0.01, Burst: 1},
+	})
+	// the first attempt takes the only token, the retry after the 429 must
+	// wait for another one which comes too late for the deadline
+	srv.Throttle(0)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err := s.Send(ctx, &Message{
+		From:    Address{Email: "john@example.com"},
+		To:      []Address{{Email: "jane@example.com"}},
+		Subject: "subject",
+		Text:    "text",
+	})
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got %v, want ErrRateLimited", err)
+	}
+	if n := len(srv.Requests()); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
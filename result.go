@@ -0,0 +1,63 @@
+package gomailer
+
+type (
+	// SendResult describes the outcome of an accepted send request. On a
+	// partial failure, such as some receipents rejected, the result is
+	// returned along with the error
+	SendResult struct {
+		Service    string      // Service represents the driver which sent the email, e.g. mailgun
+		Route      string      // Route represents the route picked by a Router, empty otherwise
+		MessageIDs []string    // MessageIDs represents the provider message id(s), one per receipent for some providers
+		Accepted   []string    // Accepted represents the receipents accepted by the provider
+		Rejected   []Rejection // Rejected represents the receipents refused by the provider
+		StatusCode int         // StatusCode represents the http status code, or the reply code for smtp
+		Raw        []byte      // Raw represents the raw response body
+	}
+
+	// Rejection describes a receipent refused by the provider
+	Rejection struct {
+		Email  string
+		Reason string
+	}
+)
+
+// MessageID return the first provider message id, or empty if the provider returned none
+func (r *SendResult) MessageID() string {
+	if r == nil || len(r.MessageIDs) <= 0 {
+		return ""
+	}
+	return r.MessageIDs[0]
+}
+
+// newSendResult return a result where every receipent is accepted, used by
+// drivers whose provider does not report a status per receipent
+func newSendResult(service string, status int, raw []byte, lists ...[]Address) *SendResult {
+	r := &SendResult{
+		Service:    service,
+		StatusCode: status,
+		Raw:        raw,
+	}
+	for _, list := range lists {
+		for _, a := range list {
+			r.Accepted = append(r.Accepted, a.Email)
+		}
+	}
+	return r
+}
+
+// merge return r with the message ids and receipents of o appended, used
+// when an email is sent in more than one request
+func (r *SendResult) merge(o *SendResult) *SendResult {
+	if r == nil {
+		return o
+	}
+	if o == nil {
+		return r
+	}
+	r.MessageIDs = append(r.MessageIDs, o.MessageIDs...)
+	r.Accepted = append(r.Accepted, o.Accepted...)
+	r.Rejected = append(r.Rejected, o.Rejected...)
+	r.StatusCode = o.StatusCode
+	r.Raw = o.Raw
+	return r
+}
@@ -0,0 +1,182 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultBaseBackoff describes the wait before the first retry
+	defaultBaseBackoff = 500 * time.Millisecond
+	// defaultMaxBackoff describes the upper bound of a single wait
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// RetryPolicy describes how a failed send is retried, the zero value disables
+// retries. Only failures which are safe to repeat are retried: connection
+// errors before the request was written, 429 and 5xx responses and 4xx smtp
+// replies
+type RetryPolicy struct {
+	MaxAttempts      int           // MaxAttempts represents the total number of attempts including the first one, <= 1 disables retries
+	BaseBackoff      time.Duration // BaseBackoff represents the wait before the first retry, doubled on every retry, default 500ms
+	MaxBackoff       time.Duration // MaxBackoff represents the upper bound of a single wait, default 30s
+	Jitter           float64       // Jitter represents the randomized fraction of a wait between 0 and 1, 0.2 means ±20%
+	IgnoreRetryAfter bool          // IgnoreRetryAfter disables honouring the Retry-After header of 429 and 503 responses
+}
+
+// enabled reports whether the policy retries at all
+func (p RetryPolicy) enabled() bool {
+	return p.MaxAttempts > 1
+}
+
+// backoff return the wait before the next attempt, attempt is the number of
+// the attempt which failed starting at 1
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	base, max := p.BaseBackoff, p.MaxBackoff
+	if base <= 0 {
+		base = defaultBaseBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if p.Jitter > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		d = time.Duration(float64(d) * (1 - jitter + 2*jitter*rand.Float64()))
+	}
+	return d
+}
+
+// wait return the wait before the next attempt honouring retryAfter, false
+// means the attempts are exhausted or the server asks to wait longer than
+// MaxBackoff
+func (p RetryPolicy) wait(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+	d := p.backoff(attempt)
+	if retryAfter > 0 && !p.IgnoreRetryAfter {
+		max := p.MaxBackoff
+		if max <= 0 {
+			max = defaultMaxBackoff
+		}
+		if retryAfter > max {
+			return 0, false
+		}
+		d = retryAfter
+	}
+	return d, true
+}
+
+// sleepContext pause for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseRetryAfter parse a Retry-After header which holds either seconds or an http date
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// retryTransport describes a round tripper which retries transient failures,
+// the request body is replayed through GetBody
+type retryTransport struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		r := req
+		if attempt > 1 {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		// a connection error is only safe to retry if nothing was written
+		var wrote int32
+		r = r.WithContext(httptrace.WithClientTrace(r.Context(), &httptrace.ClientTrace{
+			WroteHeaders: func() { atomic.StoreInt32(&wrote, 1) },
+		}))
+
+		resp, err := t.next.RoundTrip(r)
+		retryable, retryAfter := false, time.Duration(0)
+		switch {
+		case err != nil:
+			retryable = atomic.LoadInt32(&wrote) == 0 && ctx.Err() == nil
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+			retryable = true
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+		if !retryable || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		d, ok := t.policy.wait(attempt, retryAfter)
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			// drain the body so the connection goes back to the pool
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleepContext(ctx, d); err != nil {
+			return nil, err
+		}
+		// every attempt reaches the provider, so each one takes its tokens
+		if err := retryWait(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryableSMTPError reports whether an smtp send may succeed later, which is
+// the case for 4xx replies and failures to connect
+func retryableSMTPError(err error) bool {
+	var perr *ProviderError
+	if errors.As(err, &perr) {
+		return perr.Retryable()
+	}
+	var nerr *net.OpError
+	return errors.As(err, &nerr) && nerr.Op == "dial"
+}
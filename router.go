@@ -0,0 +1,142 @@
+package gomailer
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+)
+
+type (
+	// Route describes a sender a Router may pick
+	Route struct {
+		Name   string                // Name represents the route in the send result, defaults to the service of the sender
+		Sender Sender                // Sender represents the sender of the route
+		Weight int                   // Weight represents the share of traffic among the picked routes, 0 counts as 1 and a negative weight disables the route
+		Match  func(m *Message) bool // Match represents a rule on the message, nil makes the route a default one
+	}
+
+	// RouterSender describes a sender which picks one of its routes per
+	// message. Routes with a matching rule take precedence over default
+	// routes, the pick among them is random by weight. A route is skipped
+	// if its weight is negative or the attachments exceed the limit of its
+	// provider. It is safe for concurrent use
+	RouterSender struct {
+		routes []Route
+	}
+
+	// attachmentLimiter describes a sender with a max total attachment size
+	attachmentLimiter interface {
+		maxAttachmentSize() int64
+	}
+)
+
+// Router return a sender which spreads messages over routes
+func Router(routes ...Route) *RouterSender {
+	return &RouterSender{routes: routes}
+}
+
+// Send process an email sending with the route picked for the message, the
+// route name is recorded in the result
+func (r *RouterSender) Send(ctx context.Context, m *Message) (*SendResult, error) {
+	route, ok := r.pick(m)
+	if !ok {
+		return nil, ErrNoRoute
+	}
+	result, err := route.Sender.Send(ctx, m)
+	if result != nil {
+		result.Route = route.Name
+		if result.Route == "" {
+			result.Route = result.Service
+		}
+	}
+	return result, err
+}
+
+// pick return a route for the message, rules first then the default routes
+func (r *RouterSender) pick(m *Message) (Route, bool) {
+	var rules, defaults []Route
+	size := m.attachmentSize()
+	for _, route := range r.routes {
+		if route.Weight < 0 {
+			continue
+		}
+		if l, ok := route.Sender.(attachmentLimiter); ok && size > l.maxAttachmentSize() {
+			continue
+		}
+		if route.Match == nil {
+			defaults = append(defaults, route)
+		} else if route.Match(m) {
+			rules = append(rules, route)
+		}
+	}
+	if len(rules) > 0 {
+		return pickWeighted(rules), true
+	}
+	if len(defaults) > 0 {
+		return pickWeighted(defaults), true
+	}
+	return Route{}, false
+}
+
+// pickWeighted return a random route, the chance of a route is proportional to its weight
+func pickWeighted(routes []Route) Route {
+	total := 0
+	for _, route := range routes {
+		total += routeWeight(route)
+	}
+	n := rand.Intn(total)
+	for _, route := range routes {
+		if n -= routeWeight(route); n < 0 {
+			return route
+		}
+	}
+	return routes[len(routes)-1]
+}
+
+// routeWeight return the weight of a route, 1 if not set
+func routeWeight(route Route) int {
+	if route.Weight == 0 {
+		return 1
+	}
+	return route.Weight
+}
+
+// MatchRecipientDomain return a rule matching messages with any to, cc or bcc
+// receipent in one of the domains
+func MatchRecipientDomain(domains ...string) func(m *Message) bool {
+	return func(m *Message) bool {
+		for _, list := range [][]Address{m.To, m.Cc, m.Bcc} {
+			for _, a := range list {
+				i := strings.LastIndex(a.Email, "@")
+				for _, d := range domains {
+					if i >= 0 && strings.EqualFold(a.Email[i+1:], d) {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	}
+}
+
+// MatchTag return a rule matching messages with one of the tags
+func MatchTag(tags ...string) func(m *Message) bool {
+	return func(m *Message) bool {
+		for _, t := range m.Tags {
+			for _, tag := range tags {
+				if t == tag {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// MatchAttachmentSize return a rule matching messages whose attachments
+// exceed size bytes in total
+func MatchAttachmentSize(size int64) func(m *Message) bool {
+	return func(m *Message) bool {
+		return m.attachmentSize() > size
+	}
+}
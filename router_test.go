@@ -0,0 +1,73 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// limitedSender describes a stub sender with a max total attachment size
+type limitedSender struct {
+	stubSender
+	max int64
+}
+
+// maxAttachmentSize implements attachmentLimiter
+func (s *limitedSender) maxAttachmentSize() int64 {
+	return s.max
+}
+
+func TestRouterWeighted(t *testing.T) {
+	heavy, light, disabled := &stubSender{}, &stubSender{}, &stubSender{}
+	r := Router(
+		Route{Name: "heavy", Sender: heavy, Weight: 9},
+		Route{Name: "light", Sender: light},
+		Route{Name: "disabled", Sender: disabled, Weight: -1},
+	)
+	for i := 0; i < 1000; i++ {
+		if _, err := r.Send(context.Background(), &Message{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the expected split is 900 to 100, the bounds leave room for chance
+	if heavy.Calls() < 800 || light.Calls() < 30 || heavy.Calls()+light.Calls() != 1000 {
+		t.Errorf("got heavy %d light %d calls, want about 900 and 100", heavy.Calls(), light.Calls())
+	}
+	if disabled.Calls() != 0 {
+		t.Errorf("the disabled route got %d calls", disabled.Calls())
+	}
+}
+
+func TestRouterPick(t *testing.T) {
+	small := &limitedSender{max: 10}
+	large := &limitedSender{max: 1000}
+	rule := &stubSender{}
+	r := Router(
+		Route{Name: "small", Sender: small},
+		Route{Name: "large", Sender: large, Match: MatchAttachmentSize(5)},
+		Route{Name: "reset", Sender: rule, Match: MatchTag("password-reset")},
+	)
+	cases := []struct {
+		name  string
+		msg   *Message
+		route string
+	}{
+		{"default", &Message{}, "small"},
+		{"rule before default", &Message{Tags: []string{"password-reset"}}, "reset"},
+		{"attachment limit", &Message{Attachments: []Attachment{{FileName: "a.txt", Content: make([]byte, 100)}}}, "large"},
+	}
+	for _, c := range cases {
+		res, err := r.Send(context.Background(), c.msg)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if res.Route != c.route {
+			t.Errorf("%s: got route %q, want %q", c.name, res.Route, c.route)
+		}
+	}
+
+	_, err := r.Send(context.Background(), &Message{Attachments: []Attachment{{FileName: "a.txt", Content: make([]byte, 5000)}}})
+	if !errors.Is(err, ErrNoRoute) {
+		t.Errorf("got %v, want ErrNoRoute", err)
+	}
+}
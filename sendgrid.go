@@ -2,12 +2,12 @@ package gomailer
 
 import (
 	"bytes"
-	"errors"
+	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
+	"strings"
 )
 
 // https://sendgrid.com/solutions/email-api/
@@ -22,27 +22,10 @@ const (
 )
 
 type (
-	readerInfo struct {
-		size int64
-		r    io.Reader
-	}
 	// sendgrid describes a sendgrid type
 	sendgrid struct {
-		c                       client
-		configs                 Configs
-		from                    address
-		toList                  []address
-		ccList                  []address
-		bccList                 []address
-		replyTo                 address
-		subject                 string
-		bodyHTML                string
-		bodyText                string
-		attachmentFiles         []string
-		attachmentInlineFiles   []string
-		attachmentReaders       map[string]readerInfo
-		attachmentInlineReaders map[string]readerInfo
-		attachments             []attachment
+		c       client
+		configs Configs
 	}
 
 	sendgridContent struct {
@@ -60,229 +43,170 @@ func (s *sendgrid) messageURL() string {
 	return fmt.Sprintf("%s/mail/send", url)
 }
 
-// From sets an email sender address
-func (s *sendgrid) From(name, from string) Mailer {
-	s.from = address{Name: name, Email: from}
-	return s
-}
-
-// To sets receipents of an email
-func (s *sendgrid) To(name, to string) Mailer {
-	s.toList = append(s.toList, address{Name: name, Email: to})
-	return s
-}
-
-// Cc sets Cc receipents of an email
-func (s *sendgrid) Cc(name, to string) Mailer {
-	s.ccList = append(s.ccList, address{Name: name, Email: to})
-	return s
-}
-
-// Bcc sets Bcc receipents of an email
-func (s *sendgrid) Bcc(name, to string) Mailer {
-	s.bccList = append(s.bccList, address{Name: name, Email: to})
-	return s
-}
-
-// ReplyTo sets the reply-to address of an email
-func (s *sendgrid) ReplyTo(name, email string) Mailer {
-	s.replyTo = address{Name: name, Email: email}
-	return s
-}
-
-// Subject sets subject of an email
-func (s *sendgrid) Subject(subject string) Mailer {
-	s.subject = subject
-	return s
-}
-
-// BodyHTML sets html body for an email
-func (s *sendgrid) BodyHTML(body string) Mailer {
-	s.bodyHTML = body
-	return s
-}
-
-// BodyText sets plain text email body for an email
-func (s *sendgrid) BodyText(body string) Mailer {
-	s.bodyText = body
-	return s
-}
-
-// AttachmentFile set email attachments
-func (s *sendgrid) AttachmentFile(file string) Mailer {
-	s.attachmentFiles = append(s.attachmentFiles, file)
-	return s
-}
-
-// AttachmentInlineFile set email inline attachment
-func (s *sendgrid) AttachmentInlineFile(file string) Mailer {
-	s.attachmentInlineFiles = append(s.attachmentInlineFiles, file)
-	return s
-}
-
-// AttachmentReader set email attachments
-func (s *sendgrid) AttachmentReader(file string, r io.Reader) Mailer {
-	s.attachmentReaders = make(map[string]readerInfo)
-	s.attachmentReaders[file] = readerInfo{r: r}
-	return s
-}
-
-// AttachmentInlineReader set email inline attachment
-func (s *sendgrid) AttachmentInlineReader(file string, r io.Reader) Mailer {
-	s.attachmentInlineReaders = make(map[string]readerInfo)
-	s.attachmentInlineReaders[file] = readerInfo{r: r}
-	return s
-}
-
-// Send process an email sending
-func (s *sendgrid) Send() error {
+// Send process an email sending and return the provider response
+func (s *sendgrid) Send(ctx context.Context, msg *Message) (*SendResult, error) {
 	// verify params for sending email
-	s.verifyParams()
-
-	//check the total file size and path
-	var totalSize int64
-	for _, f := range s.attachmentFiles {
-		fi, e := os.Stat(f)
-		if e != nil {
-			return e
-		}
-		// get the size
-		totalSize += fi.Size()
+	if err := s.verifyParams(msg); err != nil {
+		return nil, err
 	}
 
-	if totalSize > sendgridMaxFileSize {
-		return errors.New("gomailer: max attachment size for sendgrid is 30MB")
+	// wait for the client side rate limiter if any
+	ctx, err := s.c.limiter.wait(ctx, "sendgrid", msg.receipents())
+	if err != nil {
+		return nil, err
 	}
 
-	// build attachment
-	for _, f := range s.attachmentFiles {
-		a := attachment{}
-		err := a.ReadFromFile(f)
-		if err != nil {
-			return err
-		}
-		a.Disposition = "attachment"
-		s.attachments = append(s.attachments, a)
-	}
+	return s.processSendgridRequest(ctx, msg, s.params(msg))
+}
 
+// params return the request body of an email
+func (s *sendgrid) params(msg *Message) mapData {
 	// build attachment
-	for f, r := range s.attachmentReaders {
-		a := attachment{}
-		err := a.ReadFromReader(f, r)
-		if err != nil {
-			return err
-		}
-		a.Disposition = "attachment"
-		s.attachments = append(s.attachments, a)
-	}
-
-	// build attachment inine
-	for _, f := range s.attachmentInlineFiles {
-		a := attachment{}
-		err := a.ReadFromFile(f)
-		if err != nil {
-			return err
-		}
-		a.Disposition = "inline"
-		s.attachments = append(s.attachments, a)
-	}
-
-	// build attachment inine
-	for f, r := range s.attachmentInlineReaders {
-		a := attachment{}
-		err := a.ReadFromReader(f, r)
-		if err != nil {
-			return err
-		}
-		a.Disposition = "inline"
-		s.attachments = append(s.attachments, a)
+	attachments := []attachment{}
+	for _, a := range msg.Attachments {
+		attachments = append(attachments, a.encode())
 	}
 
 	// build params
 	params := mapData{
-		"from": s.from,
+		"from": msg.From,
 	}
 
-	var to []address
-
-	if len(s.toList) > 0 {
-		to = s.toList
+	personalization := mapData{
+		"to": msg.To,
 	}
 
-	var cc, bcc []address
-
-	if len(s.ccList) > 0 {
-		cc = s.ccList
+	// sendgrid rejects an empty or null cc and bcc
+	if len(msg.Cc) > 0 {
+		personalization["cc"] = msg.Cc
 	}
-	if len(s.bccList) > 0 {
-		bcc = s.bccList
+	if len(msg.Bcc) > 0 {
+		personalization["bcc"] = msg.Bcc
 	}
 
-	personalizations := []mapData{
-		{
-			"to":      to,
-			"cc":      cc,
-			"bcc":     bcc,
-			"subject": s.subject,
-		},
+	// a template brings its own subject unless one is given
+	if msg.Template == "" || msg.Subject != "" {
+		personalization["subject"] = msg.Subject
 	}
 
-	params["personalizations"] = personalizations
+	// dynamic templates take their variables per personalization
+	if msg.Template != "" {
+		params["template_id"] = msg.Template
+		personalization["dynamic_template_data"] = msg.TemplateData
+	}
+
+	params["personalizations"] = []mapData{personalization}
 
-	if s.replyTo.Email != "" {
-		params["reply_to"] = s.replyTo
+	if msg.ReplyTo.Email != "" {
+		params["reply_to"] = msg.ReplyTo
 	}
 	var sendgridContents []sendgridContent
-	if len(s.bodyText) > 0 {
+	if len(msg.Text) > 0 {
 		sendgridContents = append(sendgridContents, sendgridContent{
 			Type:  "text/plain",
-			Value: s.bodyText,
+			Value: msg.Text,
 		})
 	}
 
-	if len(s.bodyHTML) > 0 {
+	if len(msg.HTML) > 0 {
 		sendgridContents = append(sendgridContents, sendgridContent{
 			Type:  "text/html",
-			Value: s.bodyHTML,
+			Value: msg.HTML,
 		})
 	}
 
-	params["content"] = sendgridContents
+	// a dynamic template brings its own content
+	if len(sendgridContents) > 0 || msg.Template == "" {
+		params["content"] = sendgridContents
+	}
 
 	// add attachment if exist
-	if len(s.attachments) > 0 {
-		params["attachments"] = s.attachments
+	if len(attachments) > 0 {
+		params["attachments"] = attachments
 	}
 
-	return s.processSendgridRequest(params)
+	return params
 }
 
-// verifyParams verify the required params
-func (s sendgrid) verifyParams() {
-	if s.from.Email == "" {
-		panic("gomailer: you must provide from")
+// batchSize return the max personalizations per request
+func (sendgrid) batchSize() int {
+	return sendgridMaxReceipents
+}
+
+// sendBatch send one personalization per receipent, the data is sent as
+// substitutions or as dynamic template data
+func (s *sendgrid) sendBatch(ctx context.Context, m *Message, recipients []Recipient) ([]RecipientResult, error) {
+	msg := m.clone()
+	msg.To = batchAddresses(recipients)
+
+	params := s.params(msg)
+	base := params["personalizations"].([]mapData)[0]
+	personalizations := []mapData{}
+	for _, r := range recipients {
+		p := mapData{"to": []Address{r.Address}}
+		if subject, ok := base["subject"]; ok {
+			p["subject"] = subject
+		}
+		if msg.Template != "" {
+			p["dynamic_template_data"] = r.merge(msg.TemplateData)
+		} else if len(r.Data) > 0 {
+			substitutions := map[string]string{}
+			for k, v := range r.Data {
+				substitutions[placeholder(k)] = fmt.Sprint(v)
+			}
+			p["substitutions"] = substitutions
+		}
+		personalizations = append(personalizations, p)
 	}
-	if len(s.toList) <= 0 {
-		panic("gomailer: you must provide at least one receipent")
+	params["personalizations"] = personalizations
+
+	// wait for the client side rate limiter if any
+	ctx, err := s.c.limiter.wait(ctx, "sendgrid", msg.receipents())
+	if err != nil {
+		return nil, err
 	}
-	if len(s.toList)+len(s.ccList)+len(s.bccList) > sendgridMaxReceipents {
-		panic(fmt.Sprintf("mailer: total number of receipents including to/cc/bcc can not be greater than %d for sendgrid", sendgridMaxReceipents))
+
+	res, err := s.processSendgridRequest(ctx, msg, params)
+	if err != nil {
+		return nil, err
 	}
-	if s.bodyText == "" && s.bodyHTML == "" {
-		panic("gomailer: you must provide a Text or HTML body")
+	// sendgrid returns a single message id per request
+	return batchResults(recipients, res.MessageID()), nil
+}
+
+// maxAttachmentSize return the max total size in bytes of the attachments
+func (sendgrid) maxAttachmentSize() int64 {
+	return sendgridMaxFileSize
+}
+
+// maxReceipents return the max to, cc and bcc receipents per email
+func (sendgrid) maxReceipents() int {
+	return sendgridMaxReceipents
+}
+
+// verifyParams verify the required params
+func (s sendgrid) verifyParams(msg *Message) error {
+	v := validation{service: "sendgrid"}
+	if s.configs.APIKey == "" {
+		v.add(ErrMissingCredentials, "for sendgrid you must provide APIKey in config")
 	}
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), sendgridMaxReceipents)
+	v.verifyBody(msg)
+	v.verifyAttachments(msg.attachmentSize(), sendgridMaxFileSize)
+	return v.err()
 }
 
 // processSendgridRequest perform a post request with content type application/json for sendgrid
-func (s *sendgrid) processSendgridRequest(bodyParams map[string]interface{}) error {
+func (s *sendgrid) processSendgridRequest(ctx context.Context, msg *Message, bodyParams map[string]interface{}) (*SendResult, error) {
 	body, err := toJSON(bodyParams)
 	if err != nil {
-		return err
+		return nil, newLocalError("sendgrid", err)
 	}
-	req, errReq := http.NewRequest("POST", s.messageURL(), bytes.NewBuffer(body))
+	req, errReq := http.NewRequestWithContext(ctx, "POST", s.messageURL(), bytes.NewBuffer(body))
 
 	if errReq != nil {
-		return errReq
+		return nil, newLocalError("sendgrid", errReq)
 	}
 
 	if s.configs.APIKey != "" {
@@ -293,12 +217,41 @@ func (s *sendgrid) processSendgridRequest(bodyParams map[string]interface{}) err
 
 	resp, err := s.c.getDefaultClient().Do(req)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyByte, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
 	}
 	if resp.StatusCode != http.StatusAccepted {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return errors.New(string(body))
+		return nil, s.providerError(resp.StatusCode, bodyByte)
 	}
+	// sendgrid returns an empty body, the message id comes in a header
+	r := newSendResult("sendgrid", resp.StatusCode, bodyByte, msg.To, msg.Cc, msg.Bcc)
+	if id := resp.Header.Get("X-Message-Id"); id != "" {
+		r.MessageIDs = []string{id}
+	}
+	return r, nil
+}
 
-	return err
+// providerError decode a sendgrid error response
+func (sendgrid) providerError(status int, body []byte) error {
+	result := struct {
+		Errors []struct {
+			Message string `json:"message"`
+			Field   string `json:"field"`
+		} `json:"errors"`
+	}{}
+	_ = json.Unmarshal(body, &result)
+	msgs := []string{}
+	for _, e := range result.Errors {
+		if e.Field != "" {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", e.Field, e.Message))
+			continue
+		}
+		msgs = append(msgs, e.Message)
+	}
+	return newProviderError("sendgrid", status, body, "", strings.Join(msgs, "; "))
 }
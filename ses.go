@@ -0,0 +1,230 @@
+package gomailer
+
+import (
+	"bytes"
+	"context"
+	b64 "encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// https://docs.aws.amazon.com/ses/latest/APIReference-V2/API_SendEmail.html
+
+const (
+	// sesBaseURL describes ses v2 api base url, %s is replaced by the region
+	sesBaseURL = "https://email.%s.amazonaws.com"
+	// sesService describes the service name used for signing
+	sesService = "ses"
+	// sesMaxFileSize describes the max file size in bytes for sending per email for ses
+	sesMaxFileSize int64 = 40 * 1000000
+	// sesMaxReceipents describes the max receipents per email
+	sesMaxReceipents = 50
+	// sesCharset describes the charset of the simple content
+	sesCharset = "UTF-8"
+)
+
+type (
+	// ses describes an amazon ses type
+	ses struct {
+		c       client
+		configs Configs
+	}
+
+	// sesContent describes a ses content with charset
+	sesContent struct {
+		Data    string `json:"Data"`
+		Charset string `json:"Charset,omitempty"`
+	}
+)
+
+// messageURL return a message url
+func (s *ses) messageURL() string {
+	url := fmt.Sprintf(sesBaseURL, s.configs.Region)
+	if s.configs.BaseURL != "" {
+		url = s.configs.BaseURL
+	}
+	return fmt.Sprintf("%s/v2/email/outbound-emails", url)
+}
+
+// Send process an email sending and return the provider response
+func (s *ses) Send(ctx context.Context, msg *Message) (*SendResult, error) {
+	// verify params for sending email
+	if err := s.verifyParams(msg); err != nil {
+		return nil, err
+	}
+
+	// the destination is always sent so bcc receipents are not lost in raw mode
+	destination := map[string][]string{
+		"ToAddresses": s.lists(msg.To),
+	}
+	if len(msg.Cc) > 0 {
+		destination["CcAddresses"] = s.lists(msg.Cc)
+	}
+	if len(msg.Bcc) > 0 {
+		destination["BccAddresses"] = s.lists(msg.Bcc)
+	}
+
+	params := mapData{
+		"FromEmailAddress": msg.From.mailAddress(),
+		"Destination":      destination,
+	}
+
+	if msg.ReplyTo.Email != "" {
+		params["ReplyToAddresses"] = []string{msg.ReplyTo.mailAddress()}
+	}
+
+	// a template is rendered by ses from the json encoded data, raw messages
+	// can not reference one so files are not allowed along
+	if msg.Template != "" {
+		if len(msg.Attachments) > 0 {
+			return nil, &unsupportedError{service: "ses", features: []string{"attachments with hosted templates"}}
+		}
+		vars := msg.TemplateData
+		if vars == nil {
+			vars = map[string]interface{}{}
+		}
+		data, err := toJSON(vars)
+		if err != nil {
+			return nil, newLocalError("ses", err)
+		}
+		params["Content"] = mapData{
+			"Template": mapData{
+				"TemplateName": msg.Template,
+				"TemplateData": strings.TrimSpace(string(data)),
+			},
+		}
+		return s.processSESRequest(ctx, msg, params)
+	}
+
+	// files can only be sent as a raw mime message
+	if len(msg.Attachments) > 0 {
+		raw, err := mimeMessage{Message: msg}.bytes()
+		if err != nil {
+			return nil, err
+		}
+		params["Content"] = mapData{
+			"Raw": mapData{
+				"Data": b64.StdEncoding.EncodeToString(raw),
+			},
+		}
+		return s.processSESRequest(ctx, msg, params)
+	}
+
+	body := mapData{}
+	if len(msg.Text) > 0 {
+		body["Text"] = sesContent{Data: msg.Text, Charset: sesCharset}
+	}
+	if len(msg.HTML) > 0 {
+		body["Html"] = sesContent{Data: msg.HTML, Charset: sesCharset}
+	}
+	params["Content"] = mapData{
+		"Simple": mapData{
+			"Subject": sesContent{Data: msg.Subject, Charset: sesCharset},
+			"Body":    body,
+		},
+	}
+
+	return s.processSESRequest(ctx, msg, params)
+}
+
+// lists return a list of RFC 5322 formatted email
+func (ses) lists(a []Address) []string {
+	list := []string{}
+	for _, v := range a {
+		list = append(list, v.mailAddress())
+	}
+	return list
+}
+
+// maxAttachmentSize return the max total size in bytes of the attachments
+func (ses) maxAttachmentSize() int64 {
+	return sesMaxFileSize
+}
+
+// maxReceipents return the max to, cc and bcc receipents per email
+func (ses) maxReceipents() int {
+	return sesMaxReceipents
+}
+
+// verifyParams verify the required params
+func (s ses) verifyParams(msg *Message) error {
+	v := validation{service: "ses"}
+	if s.configs.AccessKey == "" ||
+		s.configs.SecretKey == "" {
+		v.add(ErrMissingCredentials, "for ses you must provide AccessKey and SecretKey in config")
+	}
+	if s.configs.Region == "" {
+		v.add(ErrInvalidConfig, "for ses you must provide Region in config")
+	}
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), sesMaxReceipents)
+	v.verifyBody(msg)
+	v.verifyAttachments(msg.attachmentSize(), sesMaxFileSize)
+	return v.err()
+}
+
+// processSESRequest perform a signed post request with content type application/json for ses
+func (s *ses) processSESRequest(ctx context.Context, msg *Message, bodyParams map[string]interface{}) (*SendResult, error) {
+	// wait for the client side rate limiter if any
+	ctx, err := s.c.limiter.wait(ctx, "ses", msg.receipents())
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := toJSON(bodyParams)
+	if err != nil {
+		return nil, newLocalError("ses", err)
+	}
+	req, errReq := http.NewRequestWithContext(ctx, "POST", s.messageURL(), bytes.NewBuffer(body))
+	if errReq != nil {
+		return nil, newLocalError("ses", errReq)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Set("X-Amz-Content-Sha256", sha256Hex(body))
+	signV4(req, body, sigV4Credentials{
+		accessKey:    s.configs.AccessKey,
+		secretKey:    s.configs.SecretKey,
+		sessionToken: s.configs.SessionToken,
+	}, s.configs.Region, sesService, time.Now())
+
+	resp, err := s.c.getDefaultClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyByte, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s.providerError(resp.StatusCode, resp.Header.Get("X-Amzn-ErrorType"), bodyByte)
+	}
+	result := struct {
+		MessageID string `json:"MessageId"`
+	}{}
+	_ = json.Unmarshal(bodyByte, &result)
+	r := newSendResult("ses", resp.StatusCode, bodyByte, msg.To, msg.Cc, msg.Bcc)
+	if result.MessageID != "" {
+		r.MessageIDs = []string{result.MessageID}
+	}
+	return r, nil
+}
+
+// providerError decode a ses error response, the error type comes in a header
+// formatted as Type:url which only the type is kept from
+func (ses) providerError(status int, errorType string, body []byte) error {
+	result := struct {
+		Message string `json:"message"`
+	}{}
+	_ = json.Unmarshal(body, &result)
+	if i := strings.Index(errorType, ":"); i >= 0 {
+		errorType = errorType[:i]
+	}
+	return newProviderError("ses", status, body, errorType, result.Message)
+}
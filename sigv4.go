@@ -0,0 +1,116 @@
+package gomailer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+
+const (
+	// sigV4Algorithm describes the aws signature version 4 algorithm name
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	// sigV4TimeFormat describes the x-amz-date format
+	sigV4TimeFormat = "20060102T150405Z"
+	// sigV4DateFormat describes the date format of the credential scope
+	sigV4DateFormat = "20060102"
+)
+
+// sigV4Credentials describes the aws credentials used for signing
+type sigV4Credentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// signV4 sign the request in place with aws signature version 4. Every
+// header of the request is signed, an X-Amz-Content-Sha256 header set by the
+// caller is used as the payload hash, such as UNSIGNED-PAYLOAD
+func signV4(req *http.Request, body []byte, creds sigV4Credentials, region, service string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format(sigV4TimeFormat)
+	date := t.Format(sigV4DateFormat)
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = sha256Hex(body)
+	}
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	// build canonical headers, host is not part of req.Header
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	canonicalHeaders := &strings.Builder{}
+	for _, k := range names {
+		fmt.Fprintf(canonicalHeaders, "%s:%s\n", k, headers[k])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	// derive the signing key from the secret
+	key := hmacSHA256([]byte("AWS4"+creds.secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, creds.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery return the sorted and encoded query string
+func canonicalQuery(req *http.Request) string {
+	// url.Values.Encode sorts by key and escapes spaces as + which aws does not accept
+	return strings.Replace(req.URL.Query().Encode(), "+", "%20", -1)
+}
+
+// sha256Hex return the hex encoded sha256 of b
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// hmacSHA256 return the hmac sha256 of data using key
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,306 @@
+package gomailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// https://www.rfc-editor.org/rfc/rfc5321
+// https://www.rfc-editor.org/rfc/rfc5322
+
+const (
+	// smtpDefaultPort describes the default submission port, upgraded with STARTTLS when offered
+	smtpDefaultPort = 587
+	// smtpImplicitTLSPort describes the port where the connection starts with TLS
+	smtpImplicitTLSPort = 465
+	// smtpMaxReceipents describes the max receipents per email
+	smtpMaxReceipents = 100
+
+	// SMTPAuthPlain describes the PLAIN smtp auth mechanism
+	SMTPAuthPlain = "PLAIN"
+	// SMTPAuthLogin describes the LOGIN smtp auth mechanism
+	SMTPAuthLogin = "LOGIN"
+	// SMTPAuthCRAMMD5 describes the CRAM-MD5 smtp auth mechanism
+	SMTPAuthCRAMMD5 = "CRAM-MD5"
+)
+
+type (
+	// smtpMailer describes a smtp type
+	smtpMailer struct {
+		c       client
+		configs Configs
+		// dialer opens the tcp connection, net.Dialer when nil
+		dialer func(ctx context.Context, network, address string) (net.Conn, error)
+	}
+
+	// smtpLoginAuth implements the LOGIN auth mechanism which net/smtp lacks
+	smtpLoginAuth struct {
+		username string
+		password string
+		host     string
+	}
+)
+
+// Start begins the LOGIN authentication, like PLAIN in net/smtp the
+// credentials are only sent over TLS or to localhost
+func (a *smtpLoginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("gomailer: smtp LOGIN refused over an unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("gomailer: smtp LOGIN wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+// Next answers the username and password challenges
+func (a *smtpLoginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:", "user name", "username":
+		return []byte(a.username), nil
+	case "password:", "password":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("gomailer: unexpected smtp LOGIN challenge %q", fromServer)
+}
+
+// isLocalhost reports whether the smtp host is the local machine
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// address return the host:port of the smtp server
+func (s *smtpMailer) address() string {
+	port := s.configs.Port
+	if port == 0 {
+		port = smtpDefaultPort
+	}
+	return net.JoinHostPort(s.configs.Host, strconv.Itoa(port))
+}
+
+// Send process an email sending and return the provider response
+func (s *smtpMailer) Send(ctx context.Context, msg *Message) (*SendResult, error) {
+	// smtp delivers the final email, nobody renders a hosted template
+	if err := unsupportedTemplate("smtp", msg); err != nil {
+		return nil, err
+	}
+
+	// verify params for sending email
+	if err := s.verifyParams(msg); err != nil {
+		return nil, err
+	}
+
+	// the Message-ID is generated up front so it can be returned in the result
+	mm := mimeMessage{Message: msg}
+	mm.id = mm.messageID()
+	body, err := mm.bytes()
+	if err != nil {
+		return nil, newLocalError("smtp", err)
+	}
+
+	// the envelope includes bcc receipents, the headers do not
+	rcpt := []string{}
+	for _, list := range [][]Address{msg.To, msg.Cc, msg.Bcc} {
+		for _, a := range list {
+			rcpt = append(rcpt, a.Email)
+		}
+	}
+
+	// the same message, Message-ID included, is sent again on a transient failure
+	for attempt := 1; ; attempt++ {
+		// wait for the client side rate limiter if any, every attempt takes its tokens
+		if _, err := s.c.limiter.wait(ctx, "smtp", msg.receipents()); err != nil {
+			return nil, err
+		}
+		result, err := s.processSMTPRequest(ctx, msg, rcpt, body)
+		if result != nil {
+			result.MessageIDs = []string{mm.id}
+		}
+		if err == nil || !s.configs.Retry.enabled() || !retryableSMTPError(err) {
+			return result, err
+		}
+		d, ok := s.configs.Retry.wait(attempt, 0)
+		if !ok {
+			return result, err
+		}
+		if err := sleepContext(ctx, d); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// maxReceipents return the max to, cc and bcc receipents per email
+func (smtpMailer) maxReceipents() int {
+	return smtpMaxReceipents
+}
+
+// verifyParams verify the required params
+func (s smtpMailer) verifyParams(msg *Message) error {
+	v := validation{service: "smtp"}
+	if s.configs.Host == "" {
+		v.add(ErrInvalidConfig, "for smtp you must provide Host in config")
+	}
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), smtpMaxReceipents)
+	v.verifyBody(msg)
+	return v.err()
+}
+
+// tlsConfig return the tls config used for STARTTLS and implicit TLS
+func (s *smtpMailer) tlsConfig() *tls.Config {
+	if s.configs.TLSConfig != nil {
+		c := s.configs.TLSConfig.Clone()
+		if c.ServerName == "" {
+			c.ServerName = s.configs.Host
+		}
+		return c
+	}
+	return &tls.Config{ServerName: s.configs.Host}
+}
+
+// auth return the smtp auth for the configured mechanism
+func (s *smtpMailer) auth() (smtp.Auth, error) {
+	switch strings.ToUpper(s.configs.SMTPAuth) {
+	case "", SMTPAuthPlain:
+		return smtp.PlainAuth("", s.configs.Username, s.configs.Password, s.configs.Host), nil
+	case SMTPAuthLogin:
+		return &smtpLoginAuth{username: s.configs.Username, password: s.configs.Password, host: s.configs.Host}, nil
+	case SMTPAuthCRAMMD5:
+		return smtp.CRAMMD5Auth(s.configs.Username, s.configs.Password), nil
+	}
+	return nil, fmt.Errorf("gomailer: unsupported smtp auth mechanism %q", s.configs.SMTPAuth)
+}
+
+// dial open a connection to the smtp server, using TLS from the start on port 465
+func (s *smtpMailer) dial(ctx context.Context) (net.Conn, error) {
+	timeOut := s.c.timeOut
+	dial := s.dialer
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: timeOut}).DialContext
+	}
+	conn, err := dial(ctx, "tcp", s.address())
+	if err != nil {
+		return nil, err
+	}
+	// bound the whole smtp conversation by the timeout or the context deadline
+	deadline := time.Now().Add(timeOut)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if s.configs.Port == smtpImplicitTLSPort {
+		tc := tls.Client(conn, s.tlsConfig())
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tc
+	}
+	return conn, nil
+}
+
+// processSMTPRequest deliver the message to the smtp server
+func (s *smtpMailer) processSMTPRequest(ctx context.Context, msg *Message, rcpt []string, body []byte) (result *SendResult, err error) {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// net/smtp has no context support, closing the connection aborts any pending call
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		// smtp replies carry a status code like http responses
+		var terr *textproto.Error
+		if errors.As(err, &terr) {
+			err = newProviderError("smtp", terr.Code, nil, "", terr.Msg)
+		}
+	}()
+
+	c, err := smtp.NewClient(conn, s.configs.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	defer c.Close()
+
+	if hostname, err := os.Hostname(); err == nil {
+		if err := c.Hello(hostname); err != nil {
+			return nil, err
+		}
+	}
+
+	// upgrade plain connections when the server offers it, or refuse to go on when required
+	if s.configs.Port != smtpImplicitTLSPort {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(s.tlsConfig()); err != nil {
+				return nil, err
+			}
+		} else if s.configs.RequireTLS {
+			return nil, errors.New("gomailer: smtp server does not offer STARTTLS")
+		}
+	}
+
+	if s.configs.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return nil, errors.New("gomailer: smtp server does not support AUTH")
+		}
+		a, err := s.auth()
+		if err != nil {
+			return nil, err
+		}
+		if err := c.Auth(a); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.Mail(msg.From.Email); err != nil {
+		return nil, err
+	}
+	for _, r := range rcpt {
+		if err := c.Rcpt(r); err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	// the server took the message with its reply to DATA, a failed QUIT such
+	// as a 421 or a dropped connection must not send it again. The deferred
+	// Close releases the connection either way
+	_ = c.Quit()
+	return newSendResult("smtp", 250, nil, msg.To, msg.Cc, msg.Bcc), nil
+}
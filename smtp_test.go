@@ -0,0 +1,456 @@
+package gomailer
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpTestMail describes a mail received by the test server
+type smtpTestMail struct {
+	from string
+	rcpt []string
+	data []byte
+	auth string // auth represents the mechanism used, empty without AUTH
+	tls  bool   // tls represents whether the mail came over TLS
+}
+
+// smtpTestServer describes an in-process smtp server
+type smtpTestServer struct {
+	ln       net.Listener
+	tls      *tls.Config
+	startTLS bool     // startTLS represents offering STARTTLS on plain connections
+	implicit bool     // implicit represents starting every connection with TLS
+	auth     []string // auth represents the offered mechanisms
+	username string
+	password string
+	quit     string // quit represents the reply to QUIT, 221 by default
+
+	mu    sync.Mutex
+	mails []smtpTestMail
+}
+
+// newSMTPTestServer start a server on a random local port
+func newSMTPTestServer(t *testing.T, configure func(s *smtpTestServer)) *smtpTestServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpTestServer{ln: ln, username: "user", password: "secret"}
+	if configure != nil {
+		configure(s)
+	}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+// Mails return the received mails
+func (s *smtpTestServer) Mails() []smtpTestMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpTestMail(nil), s.mails...)
+}
+
+// sender return a smtp driver dialing the server whatever the configured port
+func (s *smtpTestServer) sender(c Configs) *smtpMailer {
+	c.Host = "127.0.0.1"
+	m := &smtpMailer{c: newClient(SMTP, c), configs: c}
+	m.dialer = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, s.ln.Addr().String())
+	}
+	return m
+}
+
+func (s *smtpTestServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpTestServer) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	secure := false
+	if s.implicit {
+		conn = tls.Server(conn, s.tls)
+		secure = true
+	}
+	tp := textproto.NewConn(conn)
+	mail := smtpTestMail{tls: secure}
+	tp.PrintfLine("220 localhost ESMTP test")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			verb, arg = line[:i], line[i+1:]
+		}
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			ext := []string{"localhost"}
+			if s.startTLS && !secure {
+				ext = append(ext, "STARTTLS")
+			}
+			if len(s.auth) > 0 {
+				ext = append(ext, "AUTH "+strings.Join(s.auth, " "))
+			}
+			for i, e := range ext {
+				sep := "-"
+				if i == len(ext)-1 {
+					sep = " "
+				}
+				tp.PrintfLine("250%s%s", sep, e)
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 ready to start TLS")
+			tc := tls.Server(conn, s.tls)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tc, true
+			mail.tls = true
+			tp = textproto.NewConn(conn)
+		case "AUTH":
+			mech, ok := s.authenticate(tp, arg)
+			if !ok {
+				tp.PrintfLine("535 authentication failed")
+				continue
+			}
+			mail.auth = mech
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			mail.from = smtpTestPath(arg)
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			mail.rcpt = append(mail.rcpt, smtpTestPath(arg))
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.data = data
+			s.mu.Lock()
+			s.mails = append(s.mails, mail)
+			s.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "RSET", "NOOP":
+			tp.PrintfLine("250 ok")
+		case "QUIT":
+			reply := s.quit
+			if reply == "" {
+				reply = "221 bye"
+			}
+			tp.PrintfLine("%s", reply)
+			return
+		default:
+			tp.PrintfLine("502 unknown command")
+		}
+	}
+}
+
+// authenticate run an AUTH exchange and return the mechanism on success
+func (s *smtpTestServer) authenticate(tp *textproto.Conn, arg string) (string, bool) {
+	mech, initial := arg, ""
+	if i := strings.IndexByte(arg, ' '); i >= 0 {
+		mech, initial = arg[:i], arg[i+1:]
+	}
+	mech = strings.ToUpper(mech)
+	challenge := func(c string) string {
+		tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(c)))
+		line, _ := tp.ReadLine()
+		b, _ := base64.StdEncoding.DecodeString(line)
+		return string(b)
+	}
+	switch mech {
+	case "PLAIN":
+		b, _ := base64.StdEncoding.DecodeString(initial)
+		parts := strings.Split(string(b), "\x00")
+		return mech, len(parts) == 3 && parts[1] == s.username && parts[2] == s.password
+	case "LOGIN":
+		user := challenge("Username:")
+		pass := challenge("Password:")
+		return mech, user == s.username && pass == s.password
+	case "CRAM-MD5":
+		nonce := "<1896.697170952@localhost>"
+		fields := strings.Fields(challenge(nonce))
+		h := hmac.New(md5.New, []byte(s.password))
+		h.Write([]byte(nonce))
+		return mech, len(fields) == 2 && fields[0] == s.username && fields[1] == hex.EncodeToString(h.Sum(nil))
+	}
+	return mech, false
+}
+
+// smtpTestPath return the address of a MAIL FROM or RCPT TO argument
+func smtpTestPath(arg string) string {
+	start, end := strings.IndexByte(arg, '<'), strings.LastIndexByte(arg, '>')
+	if start < 0 || end < start {
+		return ""
+	}
+	return arg[start+1 : end]
+}
+
+// smtpTestTLS return a server config with a self signed certificate for 127.0.0.1
+// and a client config trusting it
+func smtpTestTLS(t *testing.T) (*tls.Config, *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gomailer test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	return server, &tls.Config{RootCAs: pool}
+}
+
+// smtpTestMessage return a message with every kind of receipent
+func smtpTestMessage() *Message {
+	return &Message{
+		From:    Address{Name: "John Doe", Email: "john@example.com"},
+		To:      []Address{{Name: "Jane Doe", Email: "jane@example.com"}},
+		Cc:      []Address{{Email: "tom@example.com"}},
+		Bcc:     []Address{{Email: "jerry@example.com"}},
+		Subject: "Hello",
+		Text:    "plain body",
+		HTML:    "<p>html body</p>",
+	}
+}
+
+func TestSMTPAuth(t *testing.T) {
+	serverTLS, clientTLS := smtpTestTLS(t)
+	cases := []struct {
+		mech     string
+		startTLS bool
+	}{
+		{SMTPAuthPlain, false},
+		{SMTPAuthPlain, true},
+		{SMTPAuthLogin, false},
+		{SMTPAuthLogin, true},
+		{SMTPAuthCRAMMD5, false},
+	}
+	for _, tc := range cases {
+		srv := newSMTPTestServer(t, func(s *smtpTestServer) {
+			s.tls, s.startTLS = serverTLS, tc.startTLS
+			s.auth = []string{SMTPAuthPlain, SMTPAuthLogin, SMTPAuthCRAMMD5}
+		})
+		m := srv.sender(Configs{Username: "user", Password: "secret", SMTPAuth: tc.mech, TLSConfig: clientTLS})
+		if _, err := m.Send(context.Background(), smtpTestMessage()); err != nil {
+			t.Fatalf("%s starttls=%v: %v", tc.mech, tc.startTLS, err)
+		}
+		mails := srv.Mails()
+		if len(mails) != 1 {
+			t.Fatalf("%s: got %d mails, want 1", tc.mech, len(mails))
+		}
+		if mails[0].auth != tc.mech || mails[0].tls != tc.startTLS {
+			t.Errorf("%s: auth %q tls %v, want tls %v", tc.mech, mails[0].auth, mails[0].tls, tc.startTLS)
+		}
+	}
+}
+
+func TestSMTPAuthWrongPassword(t *testing.T) {
+	srv := newSMTPTestServer(t, func(s *smtpTestServer) { s.auth = []string{SMTPAuthPlain} })
+	m := srv.sender(Configs{Username: "user", Password: "wrong"})
+	_, err := m.Send(context.Background(), smtpTestMessage())
+	var perr *ProviderError
+	if !errors.As(err, &perr) || perr.StatusCode != 535 {
+		t.Fatalf("got %v, want a 535 provider error", err)
+	}
+	if len(srv.Mails()) != 0 {
+		t.Error("mail delivered without authentication")
+	}
+}
+
+func TestSMTPLoginAuthRequiresTLS(t *testing.T) {
+	a := &smtpLoginAuth{username: "user", password: "secret", host: "smtp.example.com"}
+	if _, _, err := a.Start(&smtp.ServerInfo{Name: "smtp.example.com", Auth: []string{"LOGIN"}}); err == nil {
+		t.Error("LOGIN started over an unencrypted connection to a remote host")
+	}
+	if _, _, err := a.Start(&smtp.ServerInfo{Name: "evil.example.com", TLS: true, Auth: []string{"LOGIN"}}); err == nil {
+		t.Error("LOGIN started with the wrong host name")
+	}
+	if mech, _, err := a.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: true, Auth: []string{"LOGIN"}}); err != nil || mech != "LOGIN" {
+		t.Errorf("got %q, %v over TLS", mech, err)
+	}
+}
+
+func TestSMTPRequireTLS(t *testing.T) {
+	srv := newSMTPTestServer(t, nil)
+	m := srv.sender(Configs{RequireTLS: true})
+	if _, err := m.Send(context.Background(), smtpTestMessage()); err == nil {
+		t.Fatal("sent without STARTTLS while TLS is required")
+	}
+	if len(srv.Mails()) != 0 {
+		t.Error("mail delivered in cleartext")
+	}
+
+	serverTLS, clientTLS := smtpTestTLS(t)
+	srv = newSMTPTestServer(t, func(s *smtpTestServer) { s.tls, s.startTLS = serverTLS, true })
+	m = srv.sender(Configs{RequireTLS: true, TLSConfig: clientTLS})
+	if _, err := m.Send(context.Background(), smtpTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if mails := srv.Mails(); len(mails) != 1 || !mails[0].tls {
+		t.Errorf("got %+v, want one mail over TLS", mails)
+	}
+}
+
+func TestSMTPImplicitTLS(t *testing.T) {
+	serverTLS, clientTLS := smtpTestTLS(t)
+	srv := newSMTPTestServer(t, func(s *smtpTestServer) {
+		s.tls, s.implicit = serverTLS, true
+		s.auth = []string{SMTPAuthLogin}
+	})
+	m := srv.sender(Configs{Port: smtpImplicitTLSPort, Username: "user", Password: "secret", SMTPAuth: SMTPAuthLogin, TLSConfig: clientTLS})
+	if _, err := m.Send(context.Background(), smtpTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if mails := srv.Mails(); len(mails) != 1 || !mails[0].tls || mails[0].auth != SMTPAuthLogin {
+		t.Errorf("got %+v, want one mail over TLS with LOGIN", mails)
+	}
+}
+
+func TestSMTPEnvelope(t *testing.T) {
+	srv := newSMTPTestServer(t, nil)
+	res, err := srv.sender(Configs{}).Send(context.Background(), smtpTestMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mails := srv.Mails()
+	if len(mails) != 1 {
+		t.Fatalf("got %d mails, want 1", len(mails))
+	}
+	got := mails[0]
+	if got.from != "john@example.com" {
+		t.Errorf("MAIL FROM %q", got.from)
+	}
+	if want := "jane@example.com,tom@example.com,jerry@example.com"; strings.Join(got.rcpt, ",") != want {
+		t.Errorf("RCPT TO %v, want %s", got.rcpt, want)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(got.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := msg.Header.Get("Bcc"); v != "" {
+		t.Errorf("Bcc header %q leaks the blind copy", v)
+	}
+	if strings.Contains(string(got.data), "jerry@example.com") {
+		t.Error("the blind copy address is in the data")
+	}
+	if v := msg.Header.Get("Message-ID"); v == "" || res.MessageID() != v {
+		t.Errorf("Message-ID %q, result %q", v, res.MessageID())
+	}
+}
+
+func TestSMTPQuitFailure(t *testing.T) {
+	srv := newSMTPTestServer(t, func(s *smtpTestServer) { s.quit = "421 closing" })
+	s := srv.sender(Configs{Retry: RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond}})
+	res, err := s.Send(context.Background(), smtpTestMessage())
+	if err != nil {
+		t.Fatalf("a failed QUIT after DATA failed the send: %v", err)
+	}
+	if res.MessageID() == "" {
+		t.Error("no message id")
+	}
+	if n := len(srv.Mails()); n != 1 {
+		t.Errorf("the server received %d mails, want 1", n)
+	}
+}
+
+func TestSMTPMIMETree(t *testing.T) {
+	srv := newSMTPTestServer(t, nil)
+	msg := smtpTestMessage()
+	msg.Attachments = []Attachment{
+		{FileName: "logo.png", Content: []byte("png"), Inline: true},
+		{FileName: "report.txt", Content: []byte("report")},
+	}
+	if _, err := srv.sender(Configs{}).Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	m, err := mail.ReadMessage(strings.NewReader(string(srv.Mails()[0].data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// mixed(related(alternative(text, html), logo.png), report.txt)
+	tree := smtpTestTree(t, m.Header.Get("Content-Type"), m.Body)
+	want := "multipart/mixed(multipart/related(multipart/alternative(text/plain,text/html),image/png),text/plain)"
+	if tree != want {
+		t.Errorf("got %s, want %s", tree, want)
+	}
+}
+
+// smtpTestTree return the content types of a mime tree
+func smtpTestTree(t *testing.T, contentType string, body io.Reader) string {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return mediaType
+	}
+	parts := []string{}
+	r := multipart.NewReader(bufio.NewReader(body), params["boundary"])
+	for {
+		p, err := r.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, smtpTestTree(t, p.Header.Get("Content-Type"), p))
+	}
+	return mediaType + "(" + strings.Join(parts, ",") + ")"
+}
@@ -1,3 +1,274 @@
 package gomailer
 
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
 // https://www.socketlabs.com/api-reference/injection-api/#email_req
+
+const (
+	// socketlabsBaseURL describes socketlabs injection api base url
+	socketlabsBaseURL = "https://inject.socketlabs.com/api/v1"
+	// socketlabsMaxFileSize describes the max file size in bytes for sending per email for socketlabs
+	socketlabsMaxFileSize int64 = 10 * 1000000
+	// socketlabsMaxReceipents describes the max receipents per email
+	socketlabsMaxReceipents = 50
+)
+
+type (
+	// socketlabs describes a socketlabs type
+	socketlabs struct {
+		c       client
+		configs Configs
+	}
+
+	// socketlabsAddress represents socketlabs address
+	socketlabsAddress struct {
+		Email string `json:"EmailAddress"`
+		Name  string `json:"FriendlyName,omitempty"`
+	}
+
+	// socketlabsAttachment describes an email attachment
+	socketlabsAttachment struct {
+		Name        string `json:"Name"`
+		Content     string `json:"Content"`
+		ContentType string `json:"ContentType"`
+		ContentID   string `json:"ContentId,omitempty"`
+	}
+
+	// socketlabsMergeField describes a merge field of a template, referenced as %%Field%%
+	socketlabsMergeField struct {
+		Field string `json:"Field"`
+		Value string `json:"Value"`
+	}
+
+	// socketlabsResponse describes the injection api response
+	socketlabsResponse struct {
+		ErrorCode          string `json:"ErrorCode"`
+		TransactionReceipt string `json:"TransactionReceipt"`
+		MessageResults     []struct {
+			Index          int    `json:"Index"`
+			ErrorCode      string `json:"ErrorCode"`
+			AddressResults []struct {
+				EmailAddress string `json:"EmailAddress"`
+				Accepted     bool   `json:"Accepted"`
+				ErrorCode    string `json:"ErrorCode"`
+			} `json:"AddressResults"`
+		} `json:"MessageResults"`
+	}
+)
+
+// messageURL return a message url
+func (s *socketlabs) messageURL() string {
+	url := socketlabsBaseURL
+	if s.configs.BaseURL != "" {
+		url = s.configs.BaseURL
+	}
+	return fmt.Sprintf("%s/email", url)
+}
+
+// Send process an email sending and return the provider response
+func (s *socketlabs) Send(ctx context.Context, msg *Message) (*SendResult, error) {
+	// verify params for sending email
+	if err := s.verifyParams(msg); err != nil {
+		return nil, err
+	}
+
+	// ServerID is verified to be numeric already
+	serverID, _ := strconv.Atoi(s.configs.ServerID)
+
+	// build attachment, socketlabs marks inline files with a ContentId
+	attachments := []socketlabsAttachment{}
+	for _, f := range msg.Attachments {
+		a := f.encode()
+		sa := socketlabsAttachment{
+			Name:        a.FileName,
+			Content:     a.Content,
+			ContentType: a.Type,
+		}
+		if f.Inline {
+			sa.ContentID = a.ContentID
+		}
+		attachments = append(attachments, sa)
+	}
+
+	// build params
+	message := mapData{
+		"From":    s.address(msg.From),
+		"To":      s.lists(msg.To),
+		"Subject": msg.Subject,
+	}
+
+	if len(msg.Cc) > 0 {
+		message["Cc"] = s.lists(msg.Cc)
+	}
+
+	if len(msg.Bcc) > 0 {
+		message["Bcc"] = s.lists(msg.Bcc)
+	}
+
+	if msg.ReplyTo.Email != "" {
+		message["ReplyTo"] = s.address(msg.ReplyTo)
+	}
+
+	if len(msg.Text) > 0 {
+		message["TextBody"] = msg.Text
+	}
+
+	if len(msg.HTML) > 0 {
+		message["HtmlBody"] = msg.HTML
+	}
+
+	if len(attachments) > 0 {
+		message["Attachments"] = attachments
+	}
+
+	// a template stored in the email designer renders the bodies, the data
+	// fills its merge fields as text
+	if msg.Template != "" {
+		message["ApiTemplate"] = msg.Template
+		if len(msg.TemplateData) > 0 {
+			fields := []socketlabsMergeField{}
+			for _, k := range dataKeys(msg.TemplateData) {
+				fields = append(fields, socketlabsMergeField{Field: k, Value: fmt.Sprint(msg.TemplateData[k])})
+			}
+			message["MergeData"] = mapData{"Global": fields}
+		}
+	}
+
+	params := mapData{
+		"ServerId": serverID,
+		"APIKey":   s.configs.APIKey,
+		"Messages": []mapData{message},
+	}
+
+	// wait for the client side rate limiter if any
+	ctx, err := s.c.limiter.wait(ctx, "socketlabs", msg.receipents())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.processSocketlabsRequest(ctx, msg, params)
+}
+
+// address return the socketlabs representation of an email address
+func (socketlabs) address(a Address) socketlabsAddress {
+	return socketlabsAddress{Email: a.Email, Name: a.Name}
+}
+
+// lists return a list of socketlabs addresses
+func (s socketlabs) lists(a []Address) []socketlabsAddress {
+	list := []socketlabsAddress{}
+	for _, v := range a {
+		list = append(list, s.address(v))
+	}
+	return list
+}
+
+// maxAttachmentSize return the max total size in bytes of the attachments
+func (socketlabs) maxAttachmentSize() int64 {
+	return socketlabsMaxFileSize
+}
+
+// maxReceipents return the max to, cc and bcc receipents per email
+func (socketlabs) maxReceipents() int {
+	return socketlabsMaxReceipents
+}
+
+// verifyParams verify the required params
+func (s socketlabs) verifyParams(msg *Message) error {
+	v := validation{service: "socketlabs"}
+	if s.configs.ServerID == "" ||
+		s.configs.APIKey == "" {
+		v.add(ErrMissingCredentials, "for socketlabs you must provide ServerID and APIKey in config")
+	}
+	if _, err := strconv.Atoi(s.configs.ServerID); s.configs.ServerID != "" && err != nil {
+		v.add(ErrInvalidConfig, "socketlabs ServerID %q must be numeric", s.configs.ServerID)
+	}
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), socketlabsMaxReceipents)
+	v.verifyBody(msg)
+	if msg.Template != "" && msg.Subject == "" {
+		v.add(ErrInvalidTemplate, "socketlabs api templates do not bring a subject, set one")
+	}
+	v.verifyAttachments(msg.attachmentSize(), socketlabsMaxFileSize)
+	return v.err()
+}
+
+// processSocketlabsRequest perform a post request with content type application/json for socketlabs
+func (s *socketlabs) processSocketlabsRequest(ctx context.Context, msg *Message, bodyParams map[string]interface{}) (*SendResult, error) {
+	body, err := toJSON(bodyParams)
+	if err != nil {
+		return nil, newLocalError("socketlabs", err)
+	}
+	req, errReq := http.NewRequestWithContext(ctx, "POST", s.messageURL(), bytes.NewBuffer(body))
+	if errReq != nil {
+		return nil, newLocalError("socketlabs", errReq)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := s.c.getDefaultClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyByte, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// socketlabs reports failures inside the body, even with a 200 status
+	result := socketlabsResponse{}
+	if err := json.Unmarshal(bodyByte, &result); err != nil {
+		return nil, newProviderError("socketlabs", resp.StatusCode, bodyByte, "", "undecodable response")
+	}
+	if resp.StatusCode == http.StatusOK && result.ErrorCode == "Success" {
+		return s.sendResult(msg, resp.StatusCode, bodyByte, result, nil), nil
+	}
+	reasons := []string{}
+	rejected := map[string]string{}
+	for _, m := range result.MessageResults {
+		if m.ErrorCode != "" && m.ErrorCode != "Success" {
+			reasons = append(reasons, fmt.Sprintf("message %d: %s", m.Index, m.ErrorCode))
+		}
+		for _, a := range m.AddressResults {
+			if !a.Accepted {
+				rejected[strings.ToLower(a.EmailAddress)] = a.ErrorCode
+				reasons = append(reasons, fmt.Sprintf("%s (%s)", a.EmailAddress, a.ErrorCode))
+			}
+		}
+	}
+	perr := newProviderError("socketlabs", resp.StatusCode, bodyByte, result.ErrorCode, strings.Join(reasons, ", "))
+	// a warning means the message went out to the receipents not rejected
+	if resp.StatusCode == http.StatusOK && result.ErrorCode == "Warning" {
+		return s.sendResult(msg, resp.StatusCode, bodyByte, result, rejected), perr
+	}
+	return nil, perr
+}
+
+// sendResult return the result of a sent message, the rejected receipents map
+// the lower cased email to the reason
+func (socketlabs) sendResult(msg *Message, status int, body []byte, result socketlabsResponse, rejected map[string]string) *SendResult {
+	r := &SendResult{Service: "socketlabs", StatusCode: status, Raw: body}
+	for _, list := range [][]Address{msg.To, msg.Cc, msg.Bcc} {
+		for _, a := range list {
+			if reason, ok := rejected[strings.ToLower(a.Email)]; ok {
+				r.Rejected = append(r.Rejected, Rejection{Email: a.Email, Reason: reason})
+				continue
+			}
+			r.Accepted = append(r.Accepted, a.Email)
+		}
+	}
+	if result.TransactionReceipt != "" {
+		r.MessageIDs = []string{result.TransactionReceipt}
+	}
+	return r
+}
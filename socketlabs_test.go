@@ -0,0 +1,79 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSocketlabsPartialFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		accepted []string
+		rejected []string
+	}{
+		{
+			name:     "warning",
+			response: `{"ErrorCode":"Warning","TransactionReceipt":"r1","MessageResults":[{"Index":0,"ErrorCode":"Warning","AddressResults":[{"EmailAddress":"tom@example.com","Accepted":false,"ErrorCode":"InvalidAddress"}]}]}`,
+			accepted: []string{"jane@example.com", "cc@example.com"},
+			rejected: []string{"tom@example.com"},
+		},
+		{
+			name:     "failure",
+			response: `{"ErrorCode":"InvalidAuthentication"}`,
+		},
+		{
+			name:     "undecodable",
+			response: `<html>gateway</html>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.response))
+			}))
+			defer srv.Close()
+
+			s, _ := NewSender(SOCKETLABS, Configs{APIKey: "key", ServerID: "1", BaseURL: srv.URL})
+			res, err := s.Send(context.Background(), &Message{
+				From:    Address{Email: "john@example.com"},
+				To:      []Address{{Email: "jane@example.com"}, {Email: "tom@example.com"}},
+				Cc:      []Address{{Email: "cc@example.com"}},
+				Subject: "subject",
+				Text:    "text",
+			})
+			var perr *ProviderError
+			if !errors.As(err, &perr) {
+				t.Fatalf("got %v, want a ProviderError", err)
+			}
+			if tt.accepted == nil {
+				if res != nil {
+					t.Errorf("got a result %+v for a failed send", res)
+				}
+				return
+			}
+			if res == nil {
+				t.Fatal("no result for a partially sent message")
+			}
+			if len(res.Accepted) != len(tt.accepted) || len(res.Rejected) != len(tt.rejected) {
+				t.Fatalf("got accepted %v rejected %v", res.Accepted, res.Rejected)
+			}
+			for i, e := range tt.accepted {
+				if res.Accepted[i] != e {
+					t.Errorf("accepted %d: got %s, want %s", i, res.Accepted[i], e)
+				}
+			}
+			for i, e := range tt.rejected {
+				if res.Rejected[i].Email != e || res.Rejected[i].Reason == "" {
+					t.Errorf("rejected %d: got %+v, want %s", i, res.Rejected[i], e)
+				}
+			}
+			if res.MessageID() != "r1" {
+				t.Errorf("got message id %q", res.MessageID())
+			}
+		})
+	}
+}
@@ -1,3 +1,269 @@
 package gomailer
 
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
 // https://www.sparkpost.com/features/email-api-integration/
+// https://developers.sparkpost.com/api/transmissions/
+
+const (
+	// sparkpostBaseURL describes sparkpost mail sending api base url
+	// for the EU region set Configs.BaseURL to https://api.eu.sparkpost.com/api/v1
+	sparkpostBaseURL = "https://api.sparkpost.com/api/v1"
+	// sparkpostMaxFileSize describes the max file size in bytes for sending per email for sparkpost
+	sparkpostMaxFileSize int64 = 20 * 1000000
+	// sparkpostMaxReceipents describes the max receipents per email
+	sparkpostMaxReceipents = 10000
+)
+
+type (
+	// sparkpost describes a sparkpost type
+	sparkpost struct {
+		c       client
+		configs Configs
+	}
+
+	// sparkpostAddress represents sparkpost recipient address
+	sparkpostAddress struct {
+		Name     string `json:"name,omitempty"`
+		Email    string `json:"email"`
+		HeaderTo string `json:"header_to,omitempty"`
+	}
+
+	// sparkpostRecipient represents a sparkpost recipient
+	sparkpostRecipient struct {
+		Address sparkpostAddress `json:"address"`
+	}
+
+	// sparkpostAttachment describes an email attachment or inline image
+	sparkpostAttachment struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+		Data string `json:"data"`
+	}
+)
+
+// messageURL return a message url
+func (s *sparkpost) messageURL() string {
+	url := sparkpostBaseURL
+	if s.configs.BaseURL != "" {
+		url = s.configs.BaseURL
+	}
+	return fmt.Sprintf("%s/transmissions", url)
+}
+
+// Send process an email sending and return the provider response
+func (s *sparkpost) Send(ctx context.Context, msg *Message) (*SendResult, error) {
+	// verify params for sending email
+	if err := s.verifyParams(msg); err != nil {
+		return nil, err
+	}
+
+	// build attachment, inline images are referenced by name as cid:name
+	attachments := []sparkpostAttachment{}
+	inlineImages := []sparkpostAttachment{}
+	for _, f := range msg.Attachments {
+		a := f.encode()
+		if f.Inline {
+			inlineImages = append(inlineImages, sparkpostAttachment{
+				Name: a.ContentID,
+				Type: a.Type,
+				Data: a.Content,
+			})
+			continue
+		}
+		attachments = append(attachments, sparkpostAttachment{
+			Name: a.FileName,
+			Type: a.Type,
+			Data: a.Content,
+		})
+	}
+
+	// every recipient including cc/bcc must carry the visible To header,
+	// otherwise sparkpost treats each of them as a primary recipient
+	headerTo := s.lists(msg.To)
+
+	recipients := []sparkpostRecipient{}
+	for _, a := range msg.To {
+		recipients = append(recipients, sparkpostRecipient{
+			Address: sparkpostAddress{Name: a.Name, Email: a.Email},
+		})
+	}
+	for _, a := range msg.Cc {
+		recipients = append(recipients, sparkpostRecipient{
+			Address: sparkpostAddress{Name: a.Name, Email: a.Email, HeaderTo: headerTo},
+		})
+	}
+	for _, a := range msg.Bcc {
+		recipients = append(recipients, sparkpostRecipient{
+			Address: sparkpostAddress{Name: a.Name, Email: a.Email, HeaderTo: headerTo},
+		})
+	}
+
+	// build content
+	content := mapData{
+		"from": sparkpostAddress{
+			Name:  msg.From.Name,
+			Email: msg.From.Email,
+		},
+		"subject": msg.Subject,
+	}
+
+	// only cc receipents are exposed via header, bcc stays hidden
+	if len(msg.Cc) > 0 {
+		content["headers"] = map[string]string{
+			"CC": s.lists(msg.Cc),
+		}
+	}
+
+	if msg.ReplyTo.Email != "" {
+		content["reply_to"] = msg.ReplyTo.format()
+	}
+
+	if len(msg.Text) > 0 {
+		content["text"] = msg.Text
+	}
+
+	if len(msg.HTML) > 0 {
+		content["html"] = msg.HTML
+	}
+
+	if len(attachments) > 0 {
+		content["attachments"] = attachments
+	}
+
+	if len(inlineImages) > 0 {
+		content["inline_images"] = inlineImages
+	}
+
+	params := mapData{
+		"recipients": recipients,
+		"content":    content,
+	}
+
+	// a stored template holds the whole content, the data fills its substitutions
+	if msg.Template != "" {
+		if len(msg.Attachments) > 0 {
+			return nil, &unsupportedError{service: "sparkpost", features: []string{"attachments with hosted templates"}}
+		}
+		params["content"] = mapData{"template_id": msg.Template}
+		if len(msg.TemplateData) > 0 {
+			params["substitution_data"] = msg.TemplateData
+		}
+	}
+
+	// wait for the client side rate limiter if any
+	ctx, err := s.c.limiter.wait(ctx, "sparkpost", msg.receipents())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.processSparkpostRequest(ctx, msg, params)
+}
+
+// lists return a formatted email list comma separate string
+func (sparkpost) lists(a []Address) string {
+	if len(a) <= 0 {
+		return ""
+	}
+	list := []string{}
+	for _, v := range a {
+		list = append(list, v.format())
+	}
+	return strings.Join(list, ",")
+}
+
+// maxAttachmentSize return the max total size in bytes of the attachments
+func (sparkpost) maxAttachmentSize() int64 {
+	return sparkpostMaxFileSize
+}
+
+// maxReceipents return the max to, cc and bcc receipents per email
+func (sparkpost) maxReceipents() int {
+	return sparkpostMaxReceipents
+}
+
+// verifyParams verify the required params
+func (s sparkpost) verifyParams(msg *Message) error {
+	v := validation{service: "sparkpost"}
+	if s.configs.APIKey == "" {
+		v.add(ErrMissingCredentials, "for sparkpost you must provide APIKey in config")
+	}
+	v.verifyFrom(msg.From.Email)
+	v.verifyReceipents(len(msg.To), msg.receipents(), sparkpostMaxReceipents)
+	v.verifyBody(msg)
+	v.verifyAttachments(msg.attachmentSize(), sparkpostMaxFileSize)
+	return v.err()
+}
+
+// processSparkpostRequest perform a post request with content type application/json for sparkpost
+func (s *sparkpost) processSparkpostRequest(ctx context.Context, msg *Message, bodyParams map[string]interface{}) (*SendResult, error) {
+	body, err := toJSON(bodyParams)
+	if err != nil {
+		return nil, newLocalError("sparkpost", err)
+	}
+	req, errReq := http.NewRequestWithContext(ctx, "POST", s.messageURL(), bytes.NewBuffer(body))
+	if errReq != nil {
+		return nil, newLocalError("sparkpost", errReq)
+	}
+
+	// sparkpost expects the raw api key in the Authorization header
+	req.Header.Add("Authorization", s.configs.APIKey)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := s.c.getDefaultClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyByte, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s.providerError(resp.StatusCode, bodyByte)
+	}
+	result := struct {
+		Results struct {
+			ID string `json:"id"`
+		} `json:"results"`
+	}{}
+	_ = json.Unmarshal(bodyByte, &result)
+	r := newSendResult("sparkpost", resp.StatusCode, bodyByte, msg.To, msg.Cc, msg.Bcc)
+	if result.Results.ID != "" {
+		r.MessageIDs = []string{result.Results.ID}
+	}
+	return r, nil
+}
+
+// providerError decode a sparkpost error response
+func (sparkpost) providerError(status int, body []byte) error {
+	result := struct {
+		Errors []struct {
+			Message     string `json:"message"`
+			Code        string `json:"code"`
+			Description string `json:"description"`
+		} `json:"errors"`
+	}{}
+	_ = json.Unmarshal(body, &result)
+	code, msgs := "", []string{}
+	for _, e := range result.Errors {
+		if code == "" {
+			code = e.Code
+		}
+		if e.Description != "" {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", e.Message, e.Description))
+			continue
+		}
+		msgs = append(msgs, e.Message)
+	}
+	return newProviderError("sparkpost", status, body, code, strings.Join(msgs, "; "))
+}
@@ -0,0 +1,188 @@
+package gomailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+const (
+	// subjectTemplateExt describes the file extension of a subject template
+	subjectTemplateExt = ".subject.tmpl"
+	// htmlTemplateExt describes the file extension of an html body template
+	htmlTemplateExt = ".html.tmpl"
+	// textTemplateExt describes the file extension of a plain text body template
+	textTemplateExt = ".txt.tmpl"
+)
+
+type (
+	// Templates describes email templates loaded from a file system. An email
+	// template named "welcome" is made of the files welcome.subject.tmpl,
+	// welcome.html.tmpl and welcome.txt.tmpl, any of them may be omitted. The
+	// html body is rendered with html/template, the subject and the text body
+	// with text/template. Layouts and partials are shared by every template.
+	// It is safe for concurrent use
+	Templates struct {
+		fsys  fs.FS
+		html  *htmltemplate.Template
+		text  *texttemplate.Template
+		mu    sync.Mutex
+		cache map[string]*emailTemplate
+	}
+
+	// emailTemplate describes the parsed parts of an email template
+	emailTemplate struct {
+		subject *texttemplate.Template
+		html    *htmltemplate.Template
+		text    *texttemplate.Template
+	}
+)
+
+// ParseTemplates return the templates of fsys, such as an embed.FS. The shared
+// patterns select the layouts and partials, *.html.tmpl files are available to
+// html bodies and the others to subjects and text bodies
+func ParseTemplates(fsys fs.FS, shared ...string) (*Templates, error) {
+	t := &Templates{
+		fsys:  fsys,
+		html:  htmltemplate.New(""),
+		text:  texttemplate.New(""),
+		cache: map[string]*emailTemplate{},
+	}
+	for _, pattern := range shared {
+		files, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) <= 0 {
+			return nil, fmt.Errorf("gomailer: template pattern %q matches no files", pattern)
+		}
+		for _, file := range files {
+			b, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return nil, err
+			}
+			if strings.HasSuffix(file, htmlTemplateExt) {
+				_, err = t.html.New(file).Parse(string(b))
+			} else {
+				_, err = t.text.New(file).Parse(string(b))
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return t, nil
+}
+
+// Render execute the template name with data and sets the subject and bodies of m
+func (t *Templates) Render(m *Message, name string, data interface{}) error {
+	et, err := t.lookup(name)
+	if err != nil {
+		return err
+	}
+	if et.subject != nil {
+		s, err := execute(et.subject, data)
+		if err != nil {
+			return err
+		}
+		// a subject is a single header line, the trailing newline of the file
+		// is dropped and line breaks from the data are collapsed so they can
+		// not inject headers
+		m.Subject = subjectLine(s)
+	}
+	if et.html != nil {
+		if m.HTML, err = execute(et.html, data); err != nil {
+			return err
+		}
+	}
+	if et.text != nil {
+		if m.Text, err = execute(et.text, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subjectLine return s on a single line, every line break and the spaces
+// around it become one space
+func subjectLine(s string) string {
+	lines := strings.FieldsFunc(s, func(r rune) bool { return r == '\r' || r == '\n' })
+	parts := []string{}
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l != "" {
+			parts = append(parts, l)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// lookup return the parsed template name, files are parsed on first use
+func (t *Templates) lookup(name string) (*emailTemplate, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if et, ok := t.cache[name]; ok {
+		return et, nil
+	}
+	et := &emailTemplate{}
+	found := false
+	for _, ext := range []string{subjectTemplateExt, htmlTemplateExt, textTemplateExt} {
+		file := name + ext
+		b, err := fs.ReadFile(t.fsys, file)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		found = true
+		switch ext {
+		case htmlTemplateExt:
+			c, err := t.html.Clone()
+			if err != nil {
+				return nil, err
+			}
+			if et.html, err = c.New(file).Parse(string(b)); err != nil {
+				return nil, err
+			}
+		default:
+			c, err := t.text.Clone()
+			if err != nil {
+				return nil, err
+			}
+			p, err := c.New(file).Parse(string(b))
+			if err != nil {
+				return nil, err
+			}
+			if ext == subjectTemplateExt {
+				et.subject = p
+			} else {
+				et.text = p
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("gomailer: template %q not found", name)
+	}
+	t.cache[name] = et
+	return et, nil
+}
+
+// executor describes a text or html template
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// execute return the output of a template
+func execute(tpl executor, data interface{}) (string, error) {
+	b := &bytes.Buffer{}
+	if err := tpl.Execute(b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,86 @@
+package gomailer
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// templateFS describes a template tree with a layout and partials
+var templateFS = fstest.MapFS{
+	"layouts/base.html.tmpl":    {Data: []byte(`{{define "base"}}<html>{{template "content" .}}{{template "footer"}}</html>{{end}}`)},
+	"partials/footer.html.tmpl": {Data: []byte(`{{define "footer"}}<footer>bye</footer>{{end}}`)},
+	"partials/sign.txt.tmpl":    {Data: []byte(`{{define "sign"}}-- the team{{end}}`)},
+	"welcome.subject.tmpl":      {Data: []byte("  Welcome {{.Name}}\n")},
+	"welcome.html.tmpl":         {Data: []byte(`{{define "content"}}<p>Hi {{.Name}}</p>{{end}}{{template "base" .}}`)},
+	"welcome.txt.tmpl":          {Data: []byte("Hi {{.Name}}\n{{template \"sign\"}}")},
+	"reset.subject.tmpl":        {Data: []byte("Reset for {{.Name}}\n")},
+}
+
+func TestTemplatesRender(t *testing.T) {
+	tpl, err := ParseTemplates(templateFS, "layouts/*", "partials/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		tmpl    string
+		data    map[string]string
+		subject string
+		html    string
+		text    string
+	}{
+		{
+			name:    "layout and partials",
+			tmpl:    "welcome",
+			data:    map[string]string{"Name": "Jane"},
+			subject: "Welcome Jane",
+			html:    "<html><p>Hi Jane</p><footer>bye</footer></html>",
+			text:    "Hi Jane\n-- the team",
+		},
+		{
+			name:    "html escaping",
+			tmpl:    "welcome",
+			data:    map[string]string{"Name": "<b>Jane</b>"},
+			subject: "Welcome <b>Jane</b>",
+			html:    "<html><p>Hi &lt;b&gt;Jane&lt;/b&gt;</p><footer>bye</footer></html>",
+			text:    "Hi <b>Jane</b>\n-- the team",
+		},
+		{
+			name:    "subject line breaks",
+			tmpl:    "reset",
+			data:    map[string]string{"Name": "Jane\r\nBcc: evil@example.com \n"},
+			subject: "Reset for Jane Bcc: evil@example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Message{}
+			if err := tpl.Render(m, tt.tmpl, tt.data); err != nil {
+				t.Fatal(err)
+			}
+			if m.Subject != tt.subject {
+				t.Errorf("got subject %q, want %q", m.Subject, tt.subject)
+			}
+			if m.HTML != tt.html {
+				t.Errorf("got html %q, want %q", m.HTML, tt.html)
+			}
+			if m.Text != tt.text {
+				t.Errorf("got text %q, want %q", m.Text, tt.text)
+			}
+		})
+	}
+}
+
+func TestTemplatesNotFound(t *testing.T) {
+	tpl, err := ParseTemplates(templateFS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tpl.Render(&Message{}, "missing", nil); err == nil || !strings.Contains(err.Error(), `template "missing" not found`) {
+		t.Errorf("got %v, want a not found error", err)
+	}
+	if _, err := ParseTemplates(templateFS, "layouts/*.txt.tmpl"); err == nil {
+		t.Error("a shared pattern without files was accepted")
+	}
+}
@@ -0,0 +1,154 @@
+package gomailer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHostedTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		driver   driver
+		configs  Configs
+		response string
+		path     string
+		form     bool
+		want     map[string]interface{}
+	}{
+		{
+			name:     "mandrill",
+			driver:   MANDRILL,
+			configs:  Configs{APIKey: "key"},
+			response: `[{"email":"jane@example.com","status":"sent","_id":"1"}]`,
+			path:     "/messages/send-template.json",
+			want: map[string]interface{}{
+				"template_name":             "welcome",
+				"template_content":          []interface{}{},
+				"message.merge_language":    "handlebars",
+				"message.global_merge_vars": []interface{}{map[string]interface{}{"name": "name", "content": "Jane"}},
+			},
+		},
+		{
+			name:     "socketlabs",
+			driver:   SOCKETLABS,
+			configs:  Configs{APIKey: "key", ServerID: "1"},
+			response: `{"ErrorCode":"Success","TransactionReceipt":"1"}`,
+			path:     "/email",
+			want: map[string]interface{}{
+				"Messages.0.ApiTemplate":      "welcome",
+				"Messages.0.MergeData.Global": []interface{}{map[string]interface{}{"Field": "name", "Value": "Jane"}},
+			},
+		},
+		{
+			name:     "elastic email",
+			driver:   ELASTICEMAIL,
+			configs:  Configs{APIKey: "key"},
+			response: `{"MessageID":"1"}`,
+			path:     "/emails/transactional",
+			want: map[string]interface{}{
+				"Content.TemplateName": "welcome",
+				"Content.Merge":        map[string]interface{}{"name": "Jane"},
+				"Content.Body":         nil,
+			},
+		},
+		{
+			name:     "madmimi",
+			driver:   MADMIMI,
+			configs:  Configs{Username: "user", APIKey: "key"},
+			response: `1`,
+			path:     "/mailer",
+			form:     true,
+			want: map[string]interface{}{
+				"promotion_name": "welcome",
+				"body":           "{\"name\":\"Jane\"}\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			var body []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				body, _ = ioutil.ReadAll(r.Body)
+				w.Write([]byte(tt.response))
+			}))
+			defer srv.Close()
+
+			tt.configs.BaseURL = srv.URL
+			s, _ := NewSender(tt.driver, tt.configs)
+			_, err := s.Send(context.Background(), &Message{
+				From:         Address{Email: "john@example.com"},
+				To:           []Address{{Email: "jane@example.com"}},
+				Subject:      "welcome",
+				Template:     "welcome",
+				TemplateData: map[string]interface{}{"name": "Jane"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != tt.path {
+				t.Errorf("got path %s, want %s", path, tt.path)
+			}
+			var payload interface{}
+			if tt.form {
+				form, _ := url.ParseQuery(string(body))
+				fields := map[string]interface{}{}
+				for k := range form {
+					fields[k] = form.Get(k)
+				}
+				payload = fields
+			} else if err := json.Unmarshal(body, &payload); err != nil {
+				t.Fatal(err)
+			}
+			for field, want := range tt.want {
+				if got := lookup(payload, field); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s: got %v, want %v", field, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSocketlabsTemplateSubject(t *testing.T) {
+	s, _ := NewSender(SOCKETLABS, Configs{APIKey: "key", ServerID: "1"})
+	_, err := s.Send(context.Background(), &Message{
+		From:     Address{Email: "john@example.com"},
+		To:       []Address{{Email: "jane@example.com"}},
+		Template: "welcome",
+	})
+	if !errors.Is(err, ErrInvalidTemplate) {
+		t.Errorf("got %v, want ErrInvalidTemplate", err)
+	}
+}
+
+// lookup return the value at a dotted path of a decoded json value
+func lookup(v interface{}, path string) interface{} {
+	key, rest, more := strings.Cut(path, ".")
+	var next interface{}
+	switch o := v.(type) {
+	case map[string]interface{}:
+		next = o[key]
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i >= len(o) {
+			return nil
+		}
+		next = o[i]
+	default:
+		return nil
+	}
+	if !more {
+		return next
+	}
+	return lookup(next, rest)
+}